@@ -2,6 +2,7 @@ package ctx
 
 import (
 	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/oracle"
 	"vdo-platform/pkg/setting"
 
 	"gorm.io/gorm"
@@ -13,4 +14,7 @@ const DEFAULT_COVER_IMAGE = "./cover_images/default.png"
 var Time_FMT = "2006-01-02 15:04:05"
 var Settings *setting.Settings
 var GormDb *gorm.DB
-var ChainClient *chain.ChainClient
+var ChainClient chain.Backend
+
+// PriceOracle gives the fiat values of the prices, nil if no oracle is set up
+var PriceOracle oracle.PriceOracle
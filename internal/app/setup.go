@@ -11,8 +11,10 @@ import (
 	"vdo-platform/internal/app/ctx"
 	"vdo-platform/internal/app/db"
 	"vdo-platform/internal/ginlet"
+	"vdo-platform/internal/model"
 	"vdo-platform/internal/service"
 	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/oracle"
 	"vdo-platform/pkg/setting"
 
 	"github.com/gin-gonic/gin"
@@ -40,6 +42,11 @@ func Setup(c *cli.Context) {
 		return
 	}
 
+	if err := setupPriceOracle(); err != nil {
+		log.Fatalf("init.setupPriceOracle err: %v", err)
+		return
+	}
+
 	service.Setup()
 
 	setupGin()
@@ -60,7 +67,7 @@ func (t DefaultAdminVerifyProvider) Verify(username, password string) error {
 func setupGin() {
 	gin.SetMode(ctx.Settings.ServerSetting.RunMode)
 
-	routerHandler := ginlet.NewRouter()
+	routerHandler := ginlet.NewRouter(DefaultAdminVerifyProvider{ctx.Settings})
 	httpServer := &http.Server{
 		Addr:           ":" + ctx.Settings.ServerSetting.HttpPort,
 		Handler:        routerHandler,
@@ -104,18 +111,27 @@ func setupDbEngine() error {
 
 func setupChainConnection() error {
 	// connecting chain
-	var err error
-	ctx.ChainClient, err = chain.NewChainClient(
-		ctx.Settings.Web3Setting.RpcEndpoints[0],
+	client, err := chain.NewChainClient(
+		ctx.Settings.Web3Setting.RpcEndpoints,
 		ctx.Settings.Web3Setting.Mnemonic,
 		ctx.Settings.Web3Setting.ChainId,
 	)
 	if err != nil {
 		return err
 	}
+	client.SetEraPeriod(ctx.Settings.Web3Setting.EraPeriod)
+	ctx.ChainClient = client
+	// the prices are kept in planck of the chain token or of the assets
+	model.TokenDecimals = client.TokenDecimals()
+	if symbol := ctx.Settings.Web3Setting.TokenSymbol; symbol != "" {
+		model.TokenSymbol = symbol
+	}
+	for _, a := range ctx.Settings.Web3Setting.Assets {
+		model.AddAsset(a.Symbol, a.AssetId, a.Decimals)
+	}
 	// sync block
 	for {
-		ok, err := ctx.ChainClient.GetSyncStatus()
+		ok, err := client.GetSyncStatus()
 		if err != nil {
 			return err
 		}
@@ -127,10 +143,28 @@ func setupChainConnection() error {
 	}
 	chain.InitRpcWorkPool()
 	fmt.Println("Complete synchronization of primary network block data")
+	fmt.Println("Connected rpc endpoint:", client.ActiveEndpoint())
 	fmt.Println("building chain success!")
 	return nil
 }
 
+func setupPriceOracle() error {
+	cfg := ctx.Settings.PriceOracleSetting
+	if cfg == nil || cfg.Source == "" {
+		return nil
+	}
+	switch cfg.Source {
+	case "static":
+		o, err := oracle.NewStaticOracle(cfg.File)
+		if err != nil {
+			return err
+		}
+		ctx.PriceOracle = o
+		return nil
+	}
+	return fmt.Errorf("unsupported price oracle source: %s", cfg.Source)
+}
+
 func signalHandle() {
 	log.Println("vdo-platform server startup success!")
 	var ch = make(chan os.Signal, 1)
@@ -10,6 +10,20 @@ type CreateReq struct {
 	Length      string `json:"length"`
 	Label       string `json:"label"`
 	NftType     string `json:"nftType"`
+	// royalty percentage of the secondary sales
+	Royalty uint32 `json:"royalty"`
+	// number of tokens to mint for an edition nftType
+	Editions uint32 `json:"editions"`
+	// the collection of the creator to put the video in, the royalty defaults to the collection's
+	CollectionId int64 `json:"collectionId"`
+}
+
+type CreateCollectionReq struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Banner      string `json:"banner"`
+	// default royalty percentage of the videos in the collection
+	Royalty uint32 `json:"royalty"`
 }
 
 type NftReq struct {
@@ -19,5 +33,46 @@ type NftReq struct {
 	Signtx   string `json:"signtx,omitempty"`
 	To       string `json:"to,omitempty"`
 	Price    string `json:"price,omitempty"`
+	// the asset the price is in, empty for the chain token
+	Currency string `json:"currency,omitempty"`
 	Status   string `json:"status,omitempty"`
 }
+
+type EstimateFeeReq struct {
+	// hex encoded extrinsic, signed or not
+	Extrinsic string `json:"extrinsic" binding:"required"`
+}
+
+type OpenAuctionReq struct {
+	FileHash     string `json:"filehash" binding:"required"`
+	Token        string `json:"token,omitempty"`
+	ReservePrice string `json:"reservePrice" binding:"required"`
+	// the symbol of the asset the prices are in, the chain token for empty
+	Currency string `json:"currency,omitempty"`
+	// unix timestamp in seconds
+	EndTime int64 `json:"endTime" binding:"required"`
+}
+
+type BidReq struct {
+	Amount string `json:"amount" binding:"required"`
+}
+
+type MakeOfferReq struct {
+	FileHash string `json:"filehash" binding:"required"`
+	Token    string `json:"token,omitempty"`
+	Price    string `json:"price" binding:"required"`
+	// the symbol of the asset the prices are in, the chain token for empty
+	Currency string `json:"currency,omitempty"`
+	// unix timestamp in seconds
+	ExpireAt int64 `json:"expireAt" binding:"required"`
+}
+
+type RespondOfferReq struct {
+	// accept, reject, counter, withdraw or list
+	Action string `json:"action" binding:"required"`
+	// the counter price
+	Price string `json:"price,omitempty"`
+	// the tx of the owner listing the nft at the agreed price, sent or signed
+	TxHash string `json:"txhash,omitempty"`
+	Signtx string `json:"signtx,omitempty"`
+}
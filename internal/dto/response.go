@@ -1,17 +1,150 @@
 package dto
 
+import "vdo-platform/internal/model"
+
 type CreateResp struct {
 	Creator string `json:"creator"`
 	Date    string `json:"date"`
 }
 type EventResp struct {
-	EventType string `json:"eventType"`
-	FileName  string `json:"fileName"`
-	CoverImg  string `json:"coverImg"`
-	FileHash  string `json:"fileHash"`
+	ActivityId int64      `json:"activityId,omitempty"`
+	EventType  string     `json:"eventType"`
+	FileName   string     `json:"fileName"`
+	CoverImg   string     `json:"coverImg"`
+	FileHash   string     `json:"fileHash"`
+	Token      string     `json:"token,omitempty"`
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Price      string     `json:"price"`
+	Currency   string     `json:"currency,omitempty"`
+	Fiat       *FiatValue `json:"fiat,omitempty"`
+	State      string     `json:"state"`
+	TxHash     string     `json:"txhash,omitempty"`
+	Gas        string     `json:"gas,omitempty"`
+	Date       string     `json:"date"`
+}
+
+type SignTxResp struct {
+	Extrinsic string `json:"extrinsic"`
+	// the signed extrinsic can only be included in blocks [EraBirth, EraDeath)
+	EraBirth uint64 `json:"eraBirth"`
+	EraDeath uint64 `json:"eraDeath"`
+}
+
+type FeeResp struct {
+	// fee in units of token
+	Fee string `json:"fee"`
+	// fee in the smallest unit
+	Planck string `json:"planck"`
+	Class  string `json:"class"`
+}
+
+type BalanceResp struct {
+	WalletAddress string `json:"walletAddress"`
+	Free          string `json:"free"`
+	Reserved      string `json:"reserved"`
+	Frozen        string `json:"frozen"`
+	Transferable  string `json:"transferable"`
+	Nonce         uint64 `json:"nonce"`
+}
+
+const (
+	TRANSFER_IN  = "in"
+	TRANSFER_OUT = "out"
+)
+
+type TransferResp struct {
+	TxHash    string `json:"txhash"`
 	From      string `json:"from"`
 	To        string `json:"to"`
-	Price     string `json:"price"`
-	State     string `json:"state"`
+	Amount    string `json:"amount"`
+	Fee       string `json:"fee,omitempty"`
+	Direction string `json:"direction"`
 	Date      string `json:"date"`
 }
+
+type PurchaseBuildResp struct {
+	// hex encoded unsigned extrinsic
+	Extrinsic string `json:"extrinsic"`
+	Seller    string `json:"seller"`
+	Price     string `json:"price"`
+	Currency  string `json:"currency"`
+	// the parts of the price paid to the creator and the platform
+	Royalty     string `json:"royalty"`
+	PlatformFee string `json:"platformFee"`
+}
+
+type RoyaltyResp struct {
+	TxHash   string `json:"txhash"`
+	FileHash string `json:"fileHash"`
+	Buyer    string `json:"buyer"`
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+	Date     string `json:"date"`
+}
+
+// AuctionResp is the auction with its prices in units of the currency it's priced in
+type AuctionResp struct {
+	*model.Auction
+	ReservePrice string    `json:"reservePrice"`
+	HighestBid   string    `json:"highestBid,omitempty"`
+	Currency     string    `json:"currency"`
+	BidList      []BidResp `json:"bidList,omitempty"`
+}
+
+type BidResp struct {
+	model.Bid
+	Amount string `json:"amount"`
+}
+
+// OfferResp is the offer with its prices in units of the currency it's priced in
+type OfferResp struct {
+	*model.Offer
+	Price        string `json:"price"`
+	CounterPrice string `json:"counterPrice,omitempty"`
+	Currency     string `json:"currency"`
+}
+
+// OfferListingResp is the unsigned tx listing the nft at the agreed price of the offer
+type OfferListingResp struct {
+	Extrinsic string `json:"extrinsic"`
+	Price     string `json:"price"`
+	Currency  string `json:"currency"`
+}
+
+// FiatValue is the approximate value of a price in a fiat currency
+type FiatValue struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// VideoResp is the video with its price in units of the currency it's priced in
+type VideoResp struct {
+	model.VideoMetadata
+	Price    string     `json:"price"`
+	Currency string     `json:"currency"`
+	Fiat     *FiatValue `json:"fiat,omitempty"`
+}
+
+type CollectionStats struct {
+	Items uint `json:"items"`
+	// the lowest price listed in the chain token, empty if nothing is listed
+	FloorPrice string `json:"floorPrice"`
+	// the total price of the sales in the chain token
+	Volume string `json:"volume"`
+	Sales  uint   `json:"sales"`
+	Owners uint   `json:"owners"`
+}
+
+type CollectionResp struct {
+	*model.Collection
+	Stats CollectionStats `json:"stats"`
+}
+
+type NftActionsResp struct {
+	FileHash string `json:"fileHash"`
+	Token    string `json:"token,omitempty"`
+	Status   string `json:"status"`
+	// the operations the caller can do on the nft now
+	Actions []string `json:"actions"`
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"vdo-platform/internal/ginlet/resp"
+	"vdo-platform/internal/service"
+	"vdo-platform/pkg/paging"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountAPI struct{}
+
+func NewAccountAPI() AccountAPI {
+	return AccountAPI{}
+}
+
+func (t AccountAPI) QueryBalance(c *gin.Context) {
+	res, err := service.AccountService.QueryBalance(callerWalletAddress(c))
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 500, "query balance service error"))
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (t AccountAPI) QueryTransfers(c *gin.Context) {
+	var pr paging.PageRequest
+	if err := c.ShouldBind(&pr); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind paging data error"))
+		return
+	}
+	res, err := service.AccountService.QueryTransfers(callerWalletAddress(c), pr)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 500, "query transfers service error"))
+		return
+	}
+	resp.Ok(c, res)
+}
@@ -35,7 +35,7 @@ func (t AuthAPI) LoginByEmail(c *gin.Context) {
 		resp.Error(c, err)
 		return
 	}
-	ar, err := auth.LoginByEmail(f)
+	ar, err := auth.LoginByEmail(f, c.ClientIP())
 	if err != nil {
 		resp.Error(c, err)
 		return
@@ -63,7 +63,7 @@ func (t AuthAPI) LoginByEthWallet(c *gin.Context) {
 		resp.Error(c, err)
 		return
 	}
-	ar, err := auth.LoginByEthWallet(f)
+	ar, err := auth.LoginByEthWallet(f, c.ClientIP())
 	if err != nil {
 		resp.Error(c, err)
 		return
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strconv"
+
+	"vdo-platform/internal/ginlet/resp"
+	"vdo-platform/internal/service/faucet"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type FaucetAPI struct{}
+
+func NewFaucetAPI() FaucetAPI {
+	return FaucetAPI{}
+}
+
+func (t FaucetAPI) QueryStatus(c *gin.Context) {
+	status, err := faucet.QueryStatus()
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.Ok(c, status)
+}
+
+func (t FaucetAPI) Switch(c *gin.Context) {
+	var req struct {
+		Enabled bool `json:"enabled" form:"enabled"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		resp.Error(c, err)
+		return
+	}
+	faucet.Switch(req.Enabled)
+	resp.Ok(c, faucet.IsEnabled())
+}
+
+func (t FaucetAPI) QueryGrants(c *gin.Context) {
+	var req faucet.GrantQuery
+	if err := c.ShouldBind(&req); err != nil {
+		resp.Error(c, err)
+		return
+	}
+	res, err := faucet.QueryGrants(req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (t FaucetAPI) Redrive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, errors.Wrap(err, "invalid grant id"))
+		return
+	}
+	grant, err := faucet.Redrive(id)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.Ok(c, grant)
+}
@@ -2,9 +2,14 @@ package api
 
 import (
 	"errors"
+	"net/http"
+	"strconv"
 	"vdo-platform/internal/dto"
+	"vdo-platform/internal/ginlet/middleware/auth"
 	"vdo-platform/internal/ginlet/resp"
+	"vdo-platform/internal/model"
 	"vdo-platform/internal/service/nft"
+	"vdo-platform/pkg/paging"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +20,26 @@ func NewNftAPI() NftAPI {
 	return NftAPI{}
 }
 
+func callerWalletAddress(c *gin.Context) string {
+	if li := auth.GetLoginInfo(c); li != nil && li.Claims != nil {
+		return li.Claims.WalletAddress
+	}
+	return ""
+}
+
+// nftServiceError reports the error of the nft service, the forbidden operations with 403
+// and the operations conflicting with the current state of the nft with 409
+func nftServiceError(c *gin.Context, err error, code int, wrap string) {
+	var forbidden *nft.ForbiddenError
+	switch {
+	case errors.As(err, &forbidden):
+		code = http.StatusForbidden
+	case errors.Is(err, model.ERR_VERSION_CONFLICT), errors.Is(err, model.ERR_ILLEGAL_TRANSITION):
+		code = http.StatusConflict
+	}
+	resp.Error(c, resp.NewErrorWraper(err, code, wrap))
+}
+
 func (v NftAPI) CreateVideoMetadata(c *gin.Context) {
 	var req dto.CreateReq
 	err := c.BindJSON(&req)
@@ -22,9 +47,9 @@ func (v NftAPI) CreateVideoMetadata(c *gin.Context) {
 		resp.Error(c, resp.NewErrorWraper(err, 500, "bind json data error"))
 		return
 	}
-	result, err := nft.CreateVideoMetadata(req)
+	result, err := nft.CreateVideoMetadata(callerWalletAddress(c), req)
 	if err != nil {
-		resp.Error(c, resp.NewErrorWraper(err, 500, "create video metadata service error"))
+		nftServiceError(c, err, 500, "create video metadata service error")
 		return
 	}
 	resp.Ok(c, result)
@@ -36,8 +61,8 @@ func (v NftAPI) DeleteVideoMetadata(c *gin.Context) {
 		resp.Error(c, resp.NewErrorWraper(err, 500, "bind json data error"))
 		return
 	}
-	if err := nft.DeleteVideoMetadata(hash); err != nil {
-		resp.Error(c, resp.NewErrorWraper(err, 500, "delete video metadata service error"))
+	if err := nft.DeleteVideoMetadata(hash, callerWalletAddress(c)); err != nil {
+		nftServiceError(c, err, 500, "delete video metadata service error")
 		return
 	}
 	resp.Ok(c, hash)
@@ -52,12 +77,26 @@ func (n NftAPI) QueryActivities(c *gin.Context) {
 	}
 	activities, err := nft.QueryActivitiesByQuerier(querier)
 	if err != nil {
-		resp.Error(c, resp.NewErrorWraper(err, 500, "query activities service error"))
+		nftServiceError(c, err, 500, "query activities service error")
 		return
 	}
 	resp.Ok(c, activities)
 }
 
+func (n NftAPI) QueryActivity(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid activity id"))
+		return
+	}
+	activity, err := nft.QueryActivity(id)
+	if err != nil {
+		nftServiceError(c, err, 404, "query activity service error")
+		return
+	}
+	resp.Ok(c, activity)
+}
+
 func (n NftAPI) MintNFT(c *gin.Context) {
 	act, ok := c.Params.Get("act")
 	if !ok {
@@ -70,20 +109,53 @@ func (n NftAPI) MintNFT(c *gin.Context) {
 		return
 	}
 	if act == "update" {
-		data := nft.TxData{IsSent: true, Data: req.TxHash}
-		res, err := nft.UpdateForMint(req.FileHash, data)
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForMint(req.FileHash, callerWalletAddress(c), data)
+		if err != nil {
+			nftServiceError(c, err, 500, "service error")
+			return
+		}
+		resp.Ok(c, res)
+		return
+	}
+	if act == "send" {
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForMint(req.FileHash, callerWalletAddress(c), data)
+		if err != nil {
+			nftServiceError(c, err, 500, "service error")
+			return
+		}
+		resp.Ok(c, res)
+		return
+	}
+}
+
+func (n NftAPI) MeltNFT(c *gin.Context) {
+	act, ok := c.Params.Get("act")
+	if !ok {
+		resp.Error(c, resp.NewErrorWraper(errors.New("empty act"), 400, ""))
+	}
+	var req dto.NftReq
+	err := c.BindJSON(&req)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 500, "bind json data error"))
+		return
+	}
+	if act == "update" {
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForMelt(req.FileHash, req.Token, callerWalletAddress(c), data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 500, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 	if act == "send" {
-		data := nft.TxData{IsSent: false, Data: req.Signtx}
-		res, err := nft.UpdateForMint(req.FileHash, data)
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForMelt(req.FileHash, req.Token, callerWalletAddress(c), data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 500, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
@@ -106,21 +178,30 @@ func (n NftAPI) BuyNFT(c *gin.Context) {
 	// 	resp.Error(c, resp.NewErrorWraper(err, 400, "invalid address"))
 	// 	return
 	// }
+	if act == "build" {
+		res, err := nft.BuildPurchase(req.FileHash, req.Token)
+		if err != nil {
+			nftServiceError(c, err, 400, "service error")
+			return
+		}
+		resp.Ok(c, res)
+		return
+	}
 	if act == "update" {
-		data := nft.TxData{IsSent: true, Data: req.TxHash}
-		res, err := nft.UpdateForPurchase(req.FileHash, req.To, data)
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForPurchase(req.FileHash, req.Token, callerWalletAddress(c), req.To, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 	if act == "send" {
-		data := nft.TxData{IsSent: false, Data: req.Signtx}
-		res, err := nft.UpdateForPurchase(req.FileHash, req.To, data)
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForPurchase(req.FileHash, req.Token, callerWalletAddress(c), req.To, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
@@ -144,21 +225,20 @@ func (n NftAPI) TransferNFT(c *gin.Context) {
 	// 	return
 	// }
 	if act == "update" {
-		txhash := c.PostForm("txhash")
-		data := nft.TxData{IsSent: true, Data: txhash}
-		res, err := nft.UpdateForTransfer(req.FileHash, req.To, data)
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForTransfer(req.FileHash, req.Token, callerWalletAddress(c), req.To, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 	if act == "send" {
-		data := nft.TxData{IsSent: false, Data: req.Signtx}
-		res, err := nft.UpdateForTransfer(req.FileHash, req.To, data)
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.UpdateForTransfer(req.FileHash, req.Token, callerWalletAddress(c), req.To, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
@@ -178,20 +258,20 @@ func (n NftAPI) ChangeSellingStatus(c *gin.Context) {
 		return
 	}
 	if act == "update" {
-		data := nft.TxData{IsSent: true, Data: req.TxHash}
-		res, err := nft.ChangeStatus(req.FileHash, req.Status, req.Price, data)
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.ChangeStatus(req.FileHash, req.Token, callerWalletAddress(c), req.Status, req.Price, req.Currency, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 	if act == "send" {
-		data := nft.TxData{IsSent: false, Data: req.Signtx}
-		res, err := nft.ChangeStatus(req.FileHash, req.Status, req.Price, data)
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.ChangeStatus(req.FileHash, req.Token, callerWalletAddress(c), req.Status, req.Price, req.Currency, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
@@ -211,23 +291,233 @@ func (n NftAPI) ChangeSellingPrice(c *gin.Context) {
 		return
 	}
 	if act == "update" {
-		data := nft.TxData{IsSent: true, Data: req.TxHash}
-		res, err := nft.ChangePrice(req.FileHash, req.Price, data)
+		data := nft.TxData{IsSent: true, Data: req.TxHash, Signer: callerWalletAddress(c)}
+		res, err := nft.ChangePrice(req.FileHash, req.Token, callerWalletAddress(c), req.Price, req.Currency, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 	if act == "send" {
-		data := nft.TxData{IsSent: false, Data: req.Signtx}
-		res, err := nft.ChangePrice(req.FileHash, req.Price, data)
+		data := nft.TxData{IsSent: false, Data: req.Signtx, Signer: callerWalletAddress(c)}
+		res, err := nft.ChangePrice(req.FileHash, req.Token, callerWalletAddress(c), req.Price, req.Currency, data)
 		if err != nil {
-			resp.Error(c, resp.NewErrorWraper(err, 400, "service error"))
+			nftServiceError(c, err, 400, "service error")
 			return
 		}
 		resp.Ok(c, res)
 		return
 	}
 }
+
+func (n NftAPI) EstimateFee(c *gin.Context) {
+	var req dto.EstimateFeeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.EstimateFee(req.Extrinsic)
+	if err != nil {
+		nftServiceError(c, err, 500, "estimate fee service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryRoyalties(c *gin.Context) {
+	var pr paging.PageRequest
+	if err := c.ShouldBind(&pr); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind paging data error"))
+		return
+	}
+	res, err := nft.QueryRoyalties(callerWalletAddress(c), pr)
+	if err != nil {
+		nftServiceError(c, err, 500, "query royalties service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) OpenAuction(c *gin.Context) {
+	var req dto.OpenAuctionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.OpenAuction(callerWalletAddress(c), req)
+	if err != nil {
+		nftServiceError(c, err, 400, "open auction service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) PlaceBid(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid auction id"))
+		return
+	}
+	var req dto.BidReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.PlaceBid(id, callerWalletAddress(c), req.Amount)
+	if err != nil {
+		nftServiceError(c, err, 400, "place bid service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryAuction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid auction id"))
+		return
+	}
+	res, err := nft.QueryAuction(id)
+	if err != nil {
+		nftServiceError(c, err, 404, "query auction service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) CreateCollection(c *gin.Context) {
+	var req dto.CreateCollectionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.CreateCollection(callerWalletAddress(c), req)
+	if err != nil {
+		nftServiceError(c, err, 400, "create collection service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryCollections(c *gin.Context) {
+	res, err := nft.QueryCollections(callerWalletAddress(c))
+	if err != nil {
+		nftServiceError(c, err, 500, "query collections service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryCollection(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid collection id"))
+		return
+	}
+	res, err := nft.QueryCollection(id)
+	if err != nil {
+		nftServiceError(c, err, 404, "query collection service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryCollectionVideos(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid collection id"))
+		return
+	}
+	var pr paging.PageRequest
+	if err := c.ShouldBind(&pr); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind paging data error"))
+		return
+	}
+	res, err := nft.QueryCollectionVideos(id, pr)
+	if err != nil {
+		nftServiceError(c, err, 500, "query collection videos service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryAllowedActions(c *gin.Context) {
+	res, err := nft.AllowedActions(c.Param("filehash"), c.Query("token"), callerWalletAddress(c))
+	if err != nil {
+		nftServiceError(c, err, 404, "query allowed actions service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryTokens(c *gin.Context) {
+	res, err := nft.QueryTokens(c.Param("filehash"))
+	if err != nil {
+		nftServiceError(c, err, 404, "query tokens service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) MakeOffer(c *gin.Context) {
+	var req dto.MakeOfferReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.MakeOffer(callerWalletAddress(c), req)
+	if err != nil {
+		nftServiceError(c, err, 400, "make offer service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) RespondOffer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid offer id"))
+		return
+	}
+	var req dto.RespondOfferReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.RespondOffer(id, callerWalletAddress(c), req)
+	if err != nil {
+		nftServiceError(c, err, 400, "respond offer service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) BuildOfferListing(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "invalid offer id"))
+		return
+	}
+	res, err := nft.BuildOfferListing(id, callerWalletAddress(c))
+	if err != nil {
+		nftServiceError(c, err, 400, "build offer listing service error")
+		return
+	}
+	resp.Ok(c, res)
+}
+
+func (n NftAPI) QueryOffers(c *gin.Context) {
+	var req dto.NftReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.NewErrorWraper(err, 400, "bind json data error"))
+		return
+	}
+	res, err := nft.QueryOffers(callerWalletAddress(c), req.FileHash)
+	if err != nil {
+		nftServiceError(c, err, 500, "query offers service error")
+		return
+	}
+	resp.Ok(c, res)
+}
@@ -123,8 +123,8 @@ func (v VideoAPI) AddVideoViews(c *gin.Context) {
 		return
 	}
 	video = res[0]
+	err = video.AddViews(ctx.GormDb)
 	video.Views++
-	err = video.Update(ctx.GormDb)
 	if err != nil {
 		resp.Error(c, resp.NewErrorWraper(err, 500, "update views error"))
 		return
@@ -61,3 +61,24 @@ func GetLoginInfo(c *gin.Context) *LoginInfo {
 	}
 	return nil
 }
+
+type AdminVerifier interface {
+	Verify(username, password string) error
+}
+
+// AdminRequired authenticates the admin by http basic auth
+func AdminRequired(verifier AdminVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="admin"`)
+			resp.ErrorWithHttpStatus(c, errors.New("admin authorization required"), http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+		if err := verifier.Verify(username, password); err != nil {
+			resp.ErrorWithHttpStatus(c, err, http.StatusUnauthorized)
+			c.Abort()
+		}
+	}
+}
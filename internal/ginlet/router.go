@@ -14,7 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter() *gin.Engine {
+func NewRouter(adminVerifier auth.AdminVerifier) *gin.Engine {
 	router := gin.New()
 	router.Use(logger.SetLogger())
 	router.Use(configedCors())
@@ -28,6 +28,8 @@ func NewRouter() *gin.Engine {
 	registerEndpointsForSys(router)
 	registerEndpointsForVideo(router)
 	registerEndpointsForNFT(router)
+	registerEndpointsForAccount(router)
+	registerEndpointsForAdmin(router, adminVerifier)
 	router.StaticFile("/favicon.ico", "./static/favicon.ico")
 	return router
 }
@@ -70,14 +72,41 @@ func registerEndpointsForNFT(router *gin.Engine) {
 		g.PUT("/create", n.CreateVideoMetadata)
 		g.PUT("/mint/:act", n.MintNFT)
 		g.PUT("/purchase/:act", n.BuyNFT)
+		g.PUT("/melt/:act", n.MeltNFT)
 		g.PUT("/transfer/:act", n.TransferNFT)
 		g.PUT("/change/status/:act", n.ChangeSellingStatus)
 		g.PUT("/change/price/:act", n.ChangeSellingPrice)
 		g.PUT("/activity/list", n.QueryActivities)
+		g.GET("/activity/:id", n.QueryActivity)
+		g.PUT("/estimate-fee", n.EstimateFee)
+		g.PUT("/royalties", n.QueryRoyalties)
+		g.PUT("/auction", n.OpenAuction)
+		g.PUT("/auction/:id/bid", n.PlaceBid)
+		g.GET("/auction/:id", n.QueryAuction)
+		g.PUT("/offer", n.MakeOffer)
+		g.PUT("/offer/:id", n.RespondOffer)
+		g.GET("/offer/:id/listing", n.BuildOfferListing)
+		g.PUT("/offers", n.QueryOffers)
+		g.GET("/tokens/:filehash", n.QueryTokens)
+		g.GET("/actions/:filehash", n.QueryAllowedActions)
+		g.PUT("/collection", n.CreateCollection)
+		g.GET("/collections", n.QueryCollections)
+		g.GET("/collection/:id", n.QueryCollection)
+		g.PUT("/collection/:id/videos", n.QueryCollectionVideos)
 		g.PUT("/delete", n.DeleteVideoMetadata)
 	}
 }
 
+func registerEndpointsForAccount(router *gin.Engine) {
+	a := api.NewAccountAPI()
+	g := router.Group("/account")
+	g.Use(auth.AuthRequired)
+	{
+		g.GET("/balance", a.QueryBalance)
+		g.PUT("/transfers", a.QueryTransfers)
+	}
+}
+
 func registerEndpointsForSys(router *gin.Engine) {
 	s := api.NewAuthAPI()
 	g := router.Group("/auth")
@@ -90,3 +119,15 @@ func registerEndpointsForSys(router *gin.Engine) {
 		g.PUT("/sign-tx", s.SignTx)
 	}
 }
+
+func registerEndpointsForAdmin(router *gin.Engine, verifier auth.AdminVerifier) {
+	f := api.NewFaucetAPI()
+	g := router.Group("/admin")
+	g.Use(auth.AdminRequired(verifier))
+	{
+		g.GET("/faucet/status", f.QueryStatus)
+		g.PUT("/faucet/switch", f.Switch)
+		g.PUT("/faucet/grants", f.QueryGrants)
+		g.PUT("/faucet/grants/:id/redrive", f.Redrive)
+	}
+}
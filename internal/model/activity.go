@@ -11,18 +11,28 @@ const (
 	FAILED
 	LISTENING
 	WITHDRAW
+	DROPPED
+	IN_BLOCK  //tx included in a block not finalized yet
+	FINALIZED //tx included in a finalized block
+	REORGED   //the block including the tx was reorged away
+	PENDING   //waiting for an answer off chain without a tx, such as an open offer
+	DIVERGED  //the chain executed the tx of a failed activity, recorded without being applied
 )
 
 type EventType int32
 
 const (
-	ACT_CREATE EventType = iota //nft metadata create
-	ACT_MINT                    //nft mint
-	ACT_TS                      //nft transfer
-	ACT_TX                      //nft transaction
-	ACT_MELT                    //nft melt
-	ACT_ALT                     //status alter(price change or list and     )
-	ACT_FPG                     //file storage progress
+	ACT_CREATE  EventType = iota //nft metadata create
+	ACT_MINT                     //nft mint
+	ACT_TS                       //nft transfer
+	ACT_TX                       //nft transaction
+	ACT_MELT                     //nft melt
+	ACT_ALT                      //status alter(price change or list and     )
+	ACT_FPG                      //file storage progress
+	ACT_BT                       //balance transfer
+	ACT_ROYALTY                  //royalty paid to the creator
+	ACT_BID                      //auction bid
+	ACT_OFFER                    //offer to buy
 )
 
 type Activity struct {
@@ -33,12 +43,22 @@ type Activity struct {
 	Target    string `json:"to"`
 	FileHash  string `gorm:"not null" json:"fileHash"`
 	NftToken  string `json:"nftToken,omitempty"`
-	Price     string `json:"price,omitempty"`
-	State     string `json:"state"`
-	TxHash    string `json:"txhash,omitempty"`
-	Gas       string `json:"gas,omitempty"`
-	StartDate string `gorm:"not null" json:"-"`
-	EndDate   string `gorm:"not null" json:"date"`
+	Price     Money  `gorm:"type:decimal(40,0);default:NULL" json:"price"`
+	// the asset the price is in, empty for the chain token
+	Currency string `gorm:"size:16;not null;default:''" json:"currency"`
+	// the shares of a purchase paid to the creator and to the platform, kept as the buyer signed for them
+	Royalty     Money  `gorm:"type:decimal(40,0);default:NULL" json:"-"`
+	PlatformFee Money  `gorm:"type:decimal(40,0);default:NULL" json:"-"`
+	Platform    string `gorm:"size:64" json:"-"`
+	State       string `json:"state"`
+	TxHash      string `json:"txhash,omitempty"`
+	BlockHash   string `gorm:"size:66" json:"blockHash,omitempty"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	Gas         string `json:"gas,omitempty"`
+	Signer      string `gorm:"size:64" json:"-"`
+	SignedTx    string `gorm:"type:text" json:"-"`
+	StartDate   string `gorm:"not null" json:"-"`
+	EndDate     string `gorm:"not null" json:"date"`
 }
 
 func (t ActivityState) String() string {
@@ -51,10 +71,32 @@ func (t ActivityState) String() string {
 		return "listening"
 	case WITHDRAW:
 		return "withdraw"
+	case DROPPED:
+		return "dropped"
+	case IN_BLOCK:
+		return "inblock"
+	case FINALIZED:
+		return "finalized"
+	case REORGED:
+		return "reorged"
+	case PENDING:
+		return "pending"
+	case DIVERGED:
+		return "diverged"
 	}
 	return "unknow"
 }
 
+// TrackingStates are the states of the activities whose tx is still tracked
+func TrackingStates() []string {
+	return []string{LISTENING.String(), IN_BLOCK.String()}
+}
+
+// TxBoundStates are the states in which the tx hash of an activity can't be bound again
+func TxBoundStates() []string {
+	return []string{SUCCESS.String(), LISTENING.String(), IN_BLOCK.String(), FINALIZED.String(), DIVERGED.String()}
+}
+
 func (t EventType) String() string {
 	switch t {
 	case ACT_CREATE:
@@ -71,6 +113,14 @@ func (t EventType) String() string {
 		return "alt"
 	case ACT_FPG:
 		return "fpg"
+	case ACT_BT:
+		return "bt"
+	case ACT_ROYALTY:
+		return "royalty"
+	case ACT_BID:
+		return "bid"
+	case ACT_OFFER:
+		return "offer"
 	}
 	return "unknow"
 }
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AuctionState int32
+
+const (
+	AUCTION_OPEN      AuctionState = iota
+	AUCTION_AWARDED                //ended with a winner who is yet to buy the nft
+	AUCTION_SETTLED                //the winner has bought the nft
+	AUCTION_PASSED                 //ended without a bid reaching the reserve price
+	AUCTION_UNCLAIMED              //the winner didn't buy the nft in time, the nft is released
+)
+
+func (t AuctionState) String() string {
+	switch t {
+	case AUCTION_OPEN:
+		return "open"
+	case AUCTION_AWARDED:
+		return "awarded"
+	case AUCTION_SETTLED:
+		return "settled"
+	case AUCTION_PASSED:
+		return "passed"
+	case AUCTION_UNCLAIMED:
+		return "unclaimed"
+	}
+	return "unknown"
+}
+
+// Auction is an english auction of a nft, the prices are kept in planck
+type Auction struct {
+	Id           int64  `gorm:"primary_key;auto_increment" json:"id"`
+	FileHash     string `gorm:"size:128;index;not null" json:"fileHash"`
+	NftToken     string `gorm:"size:160;index" json:"nftToken"`
+	Seller       string `gorm:"size:64;not null" json:"seller"`
+	ReservePrice Money  `gorm:"type:decimal(40,0);not null" json:"reservePrice"`
+	HighestBid   Money  `gorm:"type:decimal(40,0);default:NULL" json:"highestBid"`
+	// the asset the prices are in, empty for the chain token
+	Currency      string    `gorm:"size:16;not null;default:''" json:"currency"`
+	HighestBidder string    `gorm:"size:64" json:"highestBidder,omitempty"`
+	Bids          int64     `json:"bids"`
+	State         string    `gorm:"size:16;index;not null" json:"state"`
+	EndTime       time.Time `gorm:"index" json:"endTime"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type Bid struct {
+	Id        int64     `gorm:"primary_key;auto_increment" json:"id"`
+	AuctionId int64     `gorm:"index;not null" json:"auctionId"`
+	Bidder    string    `gorm:"size:64;not null" json:"bidder"`
+	Amount    Money     `gorm:"type:decimal(40,0);not null" json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (t *Auction) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+func (t *Auction) Take(db *gorm.DB) error {
+	return db.Take(t, t.Id).Error
+}
+
+func (t *Auction) Update(db *gorm.DB) error {
+	return db.Save(t).Error
+}
+
+func QueryAuctions(db *gorm.DB, query any, args ...any) (res []Auction, err error) {
+	err = db.Where(query, args...).Find(&res).Error
+	return
+}
+
+func (t *Bid) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+func QueryBids(db *gorm.DB, auctionId int64) (res []Bid, err error) {
+	err = db.Where("auction_id = ?", auctionId).Order("id DESC").Find(&res).Error
+	return
+}
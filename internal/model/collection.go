@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Collection groups the videos of a creator under one brand, its royalty is the default of the videos created in it
+type Collection struct {
+	Id          int64     `gorm:"primary_key;auto_increment" json:"id"`
+	Name        string    `gorm:"size:128;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Banner      string    `json:"banner"`
+	Creator     string    `gorm:"size:64;index;not null" json:"creator"`
+	Royalty     uint32    `gorm:"not null;default:0" json:"royalty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (t *Collection) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+func (t *Collection) Take(db *gorm.DB) error {
+	return db.Take(t, t.Id).Error
+}
+
+func QueryCollections(db *gorm.DB, query any, args ...any) (res []Collection, err error) {
+	err = db.Where(query, args...).Order("id DESC").Find(&res).Error
+	return
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TokenSymbol is the symbol of the chain token, it's set from the settings
+var TokenSymbol = DEFAULT_CHAIN
+
+var ERR_UNKNOWN_CURRENCY = errors.New("unknown currency")
+
+// Currency is what a nft can be priced in, the chain token or an asset of the asset pallet.
+// The prices are kept in the smallest unit of their currency.
+type Currency struct {
+	Symbol   string
+	AssetId  uint32
+	Decimals uint32
+	native   bool
+}
+
+// Assets are the currencies of the asset pallet the nfts can be priced in besides the chain token,
+// by the upper case symbol. They're set from the settings.
+var Assets = map[string]Currency{}
+
+// AddAsset makes the asset a currency the nfts can be priced in
+func AddAsset(symbol string, assetId, decimals uint32) {
+	symbol = strings.ToUpper(symbol)
+	Assets[symbol] = Currency{Symbol: symbol, AssetId: assetId, Decimals: decimals}
+}
+
+// NativeCurrency is the chain token
+func NativeCurrency() Currency {
+	return Currency{Symbol: TokenSymbol, Decimals: TokenDecimals, native: true}
+}
+
+// LookupCurrency returns the currency of the symbol, empty or the symbol of the chain token
+// is the chain token.
+func LookupCurrency(symbol string) (Currency, error) {
+	if symbol == "" || strings.EqualFold(symbol, TokenSymbol) {
+		return NativeCurrency(), nil
+	}
+	c, ok := Assets[strings.ToUpper(symbol)]
+	if !ok {
+		return Currency{}, errors.Wrap(ERR_UNKNOWN_CURRENCY, symbol)
+	}
+	return c, nil
+}
+
+// LookupAsset returns the currency of the asset id
+func LookupAsset(assetId uint32) (Currency, error) {
+	for _, c := range Assets {
+		if c.AssetId == assetId {
+			return c, nil
+		}
+	}
+	return Currency{}, errors.Wrapf(ERR_UNKNOWN_CURRENCY, "asset %d", assetId)
+}
+
+func (c Currency) IsNative() bool {
+	return c.native
+}
+
+// Code is kept in the currency columns, empty for the chain token
+func (c Currency) Code() string {
+	if c.native {
+		return ""
+	}
+	return c.Symbol
+}
+
+// Parse parses the amount in units of the currency, empty or NULL is null
+func (c Currency) Parse(amount string) (Money, error) {
+	return parseMoney(amount, c.Decimals)
+}
+
+// Format is the amount in units of the currency, NULL for null
+func (c Currency) Format(m Money) string {
+	return m.format(c.Decimals)
+}
+
+// FormatPrice formats the price in units of its currency and gives the symbol of the currency,
+// the price in an asset no longer set up is left in the smallest unit.
+func FormatPrice(m Money, currency string) (string, string) {
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return m.format(0), currency
+	}
+	return c.Format(m), c.Symbol
+}
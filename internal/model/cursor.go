@@ -0,0 +1,27 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChainCursor records the height of the last block processed by a chain follower
+type ChainCursor struct {
+	Name      string `gorm:"primary_key;size:32"`
+	Height    uint64 `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (t *ChainCursor) Get(db *gorm.DB) error {
+	err := db.Where(&ChainCursor{Name: t.Name}).Take(t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (t *ChainCursor) Save(db *gorm.DB) error {
+	return db.Save(t).Error
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GrantState int32
+
+const (
+	GRANT_PENDING GrantState = iota
+	GRANT_SUCCESS
+	GRANT_FAILED
+	GRANT_REJECTED
+)
+
+func (t GrantState) String() string {
+	switch t {
+	case GRANT_PENDING:
+		return "pending"
+	case GRANT_SUCCESS:
+		return "success"
+	case GRANT_FAILED:
+		return "failed"
+	case GRANT_REJECTED:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// FaucetGrant is an entry of the faucet ledger, the amount is in units of token.
+// The transfer of the tx hash can only be included in blocks [EraBirth, EraDeath), 0 if unknown.
+type FaucetGrant struct {
+	Id            int64     `gorm:"primary_key;auto_increment" json:"id"`
+	WalletAddress string    `gorm:"size:64;index;not null" json:"walletAddress"`
+	ClientIp      string    `gorm:"size:64;index" json:"clientIp"`
+	Amount        int64     `gorm:"not null" json:"amount"`
+	State         string    `gorm:"size:16;index;not null" json:"state"`
+	TxHash        string    `gorm:"size:66" json:"txhash,omitempty"`
+	EraBirth      uint64    `json:"eraBirth,omitempty"`
+	EraDeath      uint64    `json:"eraDeath,omitempty"`
+	Retries       int       `json:"retries"`
+	LastError     string    `gorm:"type:text" json:"lastError,omitempty"`
+	CreatedAt     time.Time `gorm:"index" json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func (t *FaucetGrant) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+func (t *FaucetGrant) Take(db *gorm.DB) error {
+	return db.Take(t, t.Id).Error
+}
+
+func (t *FaucetGrant) Update(db *gorm.DB) error {
+	return db.Save(t).Error
+}
+
+func CountFaucetGrants(db *gorm.DB, query any, args ...any) (int64, error) {
+	var count int64
+	err := db.Model(&FaucetGrant{}).Where(query, args...).Count(&count).Error
+	return count, err
+}
+
+func SumFaucetGrantAmount(db *gorm.DB, query any, args ...any) (int64, error) {
+	var sum int64
+	err := db.Model(&FaucetGrant{}).Where(query, args...).Select("COALESCE(SUM(amount), 0)").Scan(&sum).Error
+	return sum, err
+}
@@ -5,8 +5,18 @@ import (
 )
 
 func AutoMigrate(db *gorm.DB) {
+	if err := migrateMoneyColumns(db); err != nil {
+		panic("migrate money columns error: " + err.Error())
+	}
 	db.AutoMigrate(
 		&VideoMetadata{},
 		&Activity{},
+		&ChainCursor{},
+		&FaucetGrant{},
+		&Auction{},
+		&Bid{},
+		&Offer{},
+		&Token{},
+		&Collection{},
 	)
 }
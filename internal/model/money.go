@@ -0,0 +1,182 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"vdo-platform/pkg/chain"
+
+	"gorm.io/gorm"
+)
+
+// TokenDecimals are the decimals of the chain token the amounts are parsed and formatted with,
+// it's set from the chain once connected
+var TokenDecimals uint32 = 12
+
+// Money is an amount of the token kept in planck, the smallest unit, so it's exact and sorts
+// numerically in the database. The zero value is null, the price of a nft not for sale.
+type Money struct {
+	planck *big.Int
+}
+
+// NewMoney is the amount in planck, null for nil
+func NewMoney(planck *big.Int) Money {
+	if planck == nil {
+		return Money{}
+	}
+	return Money{planck: new(big.Int).Set(planck)}
+}
+
+// ParseMoney parses the amount in units of token, empty or NULL is null
+func ParseMoney(amount string) (Money, error) {
+	return parseMoney(amount, TokenDecimals)
+}
+
+func parseMoney(amount string, decimals uint32) (Money, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" || amount == NULL {
+		return Money{}, nil
+	}
+	planck, err := chain.ParseBalance(amount, decimals)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{planck: planck}, nil
+}
+
+func (m Money) IsNull() bool {
+	return m.planck == nil
+}
+
+// Planck is the amount in planck, nil for null
+func (m Money) Planck() *big.Int {
+	if m.planck == nil {
+		return nil
+	}
+	return new(big.Int).Set(m.planck)
+}
+
+func (m Money) Equal(o Money) bool {
+	if m.planck == nil || o.planck == nil {
+		return m.planck == o.planck
+	}
+	return m.planck.Cmp(o.planck) == 0
+}
+
+// String is the amount in units of token, NULL for null
+func (m Money) String() string {
+	return m.format(TokenDecimals)
+}
+
+func (m Money) format(decimals uint32) string {
+	if m.planck == nil {
+		return NULL
+	}
+	return chain.FormatBalance(m.planck, decimals)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value stores the amount in planck
+func (m Money) Value() (driver.Value, error) {
+	if m.planck == nil {
+		return nil, nil
+	}
+	return m.planck.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = Money{planck: big.NewInt(v)}
+		return nil
+	default:
+		return fmt.Errorf("unable to scan %T into money", src)
+	}
+	planck, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid amount in planck: %s", s)
+	}
+	*m = Money{planck: planck}
+	return nil
+}
+
+// moneyColumns are the columns that used to keep the amounts in units of token as text
+var moneyColumns = []struct {
+	model  any
+	column string
+}{
+	{&VideoMetadata{}, "price"},
+	{&Activity{}, "price"},
+}
+
+// migrateMoneyColumns converts the amounts of the text money columns to planck, so the
+// auto migration can alter the columns to decimal. NULL or an invalid amount becomes NULL.
+func migrateMoneyColumns(db *gorm.DB) error {
+	for _, c := range moneyColumns {
+		if !db.Migrator().HasTable(c.model) {
+			continue
+		}
+		columns, err := db.Migrator().ColumnTypes(c.model)
+		if err != nil {
+			return err
+		}
+		text := false
+		for _, col := range columns {
+			if col.Name() == c.column {
+				typ := strings.ToLower(col.DatabaseTypeName())
+				text = strings.Contains(typ, "char") || strings.Contains(typ, "text")
+			}
+		}
+		if !text {
+			continue
+		}
+		var rows []struct {
+			Id     int64
+			Amount *string
+		}
+		err = db.Model(c.model).Select("id, " + c.column + " AS amount").Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			var planck any
+			if row.Amount != nil {
+				if m, err := ParseMoney(*row.Amount); err == nil && !m.IsNull() {
+					planck = m.planck.String()
+				}
+			}
+			err := db.Model(c.model).Where("id = ?", row.Id).UpdateColumn(c.column, planck).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
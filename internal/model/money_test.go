@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustMoney(t *testing.T, s string) Money {
+	m, err := ParseMoney(s)
+	require.NoError(t, err)
+	return m
+}
+
+func TestMoney(t *testing.T) {
+	require := require.New(t)
+
+	m := mustMoney(t, "1.5")
+	require.Equal("1500000000000", m.Planck().String())
+	require.Equal("1.5", m.String())
+	require.True(m.Equal(NewMoney(big.NewInt(1500000000000))))
+	require.False(m.Equal(Money{}))
+
+	// the planck is copied, the money can't be changed through it
+	m.Planck().SetInt64(1)
+	require.Equal("1.5", m.String())
+
+	for _, s := range []string{"", NULL, " "} {
+		null := mustMoney(t, s)
+		require.True(null.IsNull())
+		require.Equal(NULL, null.String())
+		require.Nil(null.Planck())
+	}
+	_, err := ParseMoney("1.5a")
+	require.Error(err)
+	_, err = ParseMoney("0.0000000000001")
+	require.Error(err)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	require := require.New(t)
+	b, err := json.Marshal(struct{ Price Money }{mustMoney(t, "2.25")})
+	require.NoError(err)
+	require.JSONEq(`{"Price":"2.25"}`, string(b))
+
+	var v struct{ Price Money }
+	require.NoError(json.Unmarshal([]byte(`{"Price":"0.1"}`), &v))
+	require.Equal("100000000000", v.Price.Planck().String())
+	require.NoError(json.Unmarshal([]byte(`{"Price":"--"}`), &v))
+	require.True(v.Price.IsNull())
+}
+
+func TestMoneyValueScan(t *testing.T) {
+	require := require.New(t)
+	v, err := mustMoney(t, "3").Value()
+	require.NoError(err)
+	require.Equal("3000000000000", v)
+	v, err = Money{}.Value()
+	require.NoError(err)
+	require.Nil(v)
+
+	var m Money
+	require.NoError(m.Scan([]byte("3000000000000")))
+	require.Equal("3", m.String())
+	require.NoError(m.Scan(int64(42)))
+	require.Equal("42", m.Planck().String())
+	require.NoError(m.Scan(nil))
+	require.True(m.IsNull())
+	require.Error(m.Scan("1.5"))
+}
+
+func TestCurrency(t *testing.T) {
+	require := require.New(t)
+	AddAsset("usdt", 1984, 6)
+	defer delete(Assets, "USDT")
+
+	for _, symbol := range []string{"", TokenSymbol, "cess"} {
+		c, err := LookupCurrency(symbol)
+		require.NoError(err)
+		require.True(c.IsNative())
+		require.Empty(c.Code())
+	}
+	usdt, err := LookupCurrency("Usdt")
+	require.NoError(err)
+	require.False(usdt.IsNative())
+	require.Equal("USDT", usdt.Code())
+	require.Equal(uint32(1984), usdt.AssetId)
+	_, err = LookupCurrency("DOT")
+	require.ErrorIs(err, ERR_UNKNOWN_CURRENCY)
+	c, err := LookupAsset(1984)
+	require.NoError(err)
+	require.Equal(usdt, c)
+	_, err = LookupAsset(1)
+	require.ErrorIs(err, ERR_UNKNOWN_CURRENCY)
+
+	m, err := usdt.Parse("2.5")
+	require.NoError(err)
+	require.Equal("2500000", m.Planck().String())
+	price, symbol := FormatPrice(m, "USDT")
+	require.Equal("2.5", price)
+	require.Equal("USDT", symbol)
+	price, symbol = FormatPrice(m, "")
+	require.Equal("0.0000025", price)
+	require.Equal(TokenSymbol, symbol)
+	// an asset no longer set up is left in the smallest unit
+	price, symbol = FormatPrice(m, "DOT")
+	require.Equal("2500000", price)
+	require.Equal("DOT", symbol)
+}
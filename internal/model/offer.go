@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OfferState int32
+
+const (
+	OFFER_OPEN      OfferState = iota
+	OFFER_COUNTERED            //the owner asks the buyer for another price
+	OFFER_ACCEPTED             //the nft is reserved for the buyer to buy
+	OFFER_REJECTED
+	OFFER_WITHDRAWN //withdrawn by the buyer or expired
+	OFFER_COMPLETED //the buyer has bought the nft
+)
+
+func (t OfferState) String() string {
+	switch t {
+	case OFFER_OPEN:
+		return "open"
+	case OFFER_COUNTERED:
+		return "countered"
+	case OFFER_ACCEPTED:
+		return "accepted"
+	case OFFER_REJECTED:
+		return "rejected"
+	case OFFER_WITHDRAWN:
+		return "withdrawn"
+	case OFFER_COMPLETED:
+		return "completed"
+	}
+	return "unknown"
+}
+
+// Offer is an offer to buy a nft not necessarily listed, the prices are kept in planck.
+// The offer is shown in the activities by the activity it's recorded with,
+// and the accepted offer is listed on chain by the listing activity of the owner.
+type Offer struct {
+	Id           int64  `gorm:"primary_key;auto_increment" json:"id"`
+	ActivityId   int64  `gorm:"index" json:"activityId"`
+	ListingId    int64  `json:"listingId,omitempty"`
+	FileHash     string `gorm:"size:128;index;not null" json:"fileHash"`
+	NftToken     string `gorm:"size:160;index" json:"nftToken"`
+	Buyer        string `gorm:"size:64;index;not null" json:"buyer"`
+	Owner        string `gorm:"size:64;index;not null" json:"owner"`
+	Price        Money  `gorm:"type:decimal(40,0);not null" json:"price"`
+	CounterPrice Money  `gorm:"type:decimal(40,0);default:NULL" json:"counterPrice"`
+	// the asset the prices are in, empty for the chain token
+	Currency  string    `gorm:"size:16;not null;default:''" json:"currency"`
+	State     string    `gorm:"size:16;index;not null" json:"state"`
+	ExpireAt  time.Time `gorm:"index" json:"expireAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (t *Offer) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+func (t *Offer) Take(db *gorm.DB) error {
+	return db.Take(t, t.Id).Error
+}
+
+func (t *Offer) Update(db *gorm.DB) error {
+	return db.Save(t).Error
+}
+
+func QueryOffers(db *gorm.DB, query any, args ...any) (res []Offer, err error) {
+	err = db.Where(query, args...).Order("id DESC").Find(&res).Error
+	return
+}
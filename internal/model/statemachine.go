@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NftEvent is an operation moving a nft through its statuses
+type NftEvent string
+
+const (
+	NFT_MINT     NftEvent = "mint"
+	NFT_TRANSFER NftEvent = "transfer"
+	NFT_LIST     NftEvent = "list"
+	NFT_UNLIST   NftEvent = "unlist"
+	NFT_REPRICE  NftEvent = "reprice"
+	NFT_PURCHASE NftEvent = "purchase"
+	NFT_MELT     NftEvent = "melt"
+	NFT_AUCTION  NftEvent = "auction"
+	NFT_AWARD    NftEvent = "award"   //the auction ends with a winner to buy the nft
+	NFT_RELEASE  NftEvent = "release" //the auction passes or the reserved buyer doesn't buy in time
+)
+
+// NftRole is who can fire an event on the nft
+type NftRole int32
+
+const (
+	ROLE_CREATOR  NftRole = iota
+	ROLE_OWNER            //the current owner
+	ROLE_BUYER            //anyone but the owner
+	ROLE_PLATFORM         //the platform itself, never a user
+)
+
+func (r NftRole) allows(nft *VideoMetadata, actor string) bool {
+	if actor == "" {
+		return false
+	}
+	switch r {
+	case ROLE_CREATOR:
+		return actor == nft.Creator
+	case ROLE_OWNER:
+		return actor == nft.Owner
+	case ROLE_BUYER:
+		return actor != nft.Owner
+	}
+	return false
+}
+
+var (
+	ERR_ILLEGAL_TRANSITION = errors.New("illegal nft status transition")
+	ERR_NOT_PERMITTED      = errors.New("not permitted to operate the nft")
+)
+
+// NftInput is what the operation firing an event gives to the transition
+type NftInput struct {
+	Actor string
+	// the new owner of a transfer or a purchase
+	Owner string
+	Price Money
+	// the asset the price is in, empty for the chain token
+	Currency string
+	Token    string
+}
+
+// NftTransition declares how an event moves a nft
+type NftTransition struct {
+	Event NftEvent
+	From  []NftStatus
+	To    NftStatus
+	Role  NftRole
+	// Guard is the condition of the transition besides the status and the role, nil for none
+	Guard func(nft *VideoMetadata, in NftInput) error
+	// Effect changes the nft besides the status, nil for none
+	Effect func(nft *VideoMetadata, in NftInput)
+}
+
+// NftMachine is the state machine of the nft statuses
+type NftMachine struct {
+	transitions []NftTransition
+}
+
+func NewNftMachine(transitions ...NftTransition) *NftMachine {
+	return &NftMachine{transitions: transitions}
+}
+
+func (m *NftMachine) transition(nft *VideoMetadata, evt NftEvent) (*NftTransition, error) {
+	for i := range m.transitions {
+		t := &m.transitions[i]
+		if t.Event != evt {
+			continue
+		}
+		for _, from := range t.From {
+			if from.String() == nft.NftStatus {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: unable to %s the nft in %s status", ERR_ILLEGAL_TRANSITION, evt, nft.NftStatus)
+	}
+	return nil, fmt.Errorf("%w: unknown event %s", ERR_ILLEGAL_TRANSITION, evt)
+}
+
+// Check checks the actor of the input can fire the event on the nft in its current status
+func (m *NftMachine) Check(nft *VideoMetadata, evt NftEvent, in NftInput) error {
+	t, err := m.transition(nft, evt)
+	if err != nil {
+		return err
+	}
+	if !t.Role.allows(nft, in.Actor) {
+		return fmt.Errorf("%w: %s", ERR_NOT_PERMITTED, evt)
+	}
+	if t.Guard != nil {
+		return t.Guard(nft, in)
+	}
+	return nil
+}
+
+// Fire moves the nft by the event with the side effects. The role is not checked, the event is
+// either fired by the platform or checked when the operation was requested.
+func (m *NftMachine) Fire(nft *VideoMetadata, evt NftEvent, in NftInput) error {
+	t, err := m.transition(nft, evt)
+	if err != nil {
+		return err
+	}
+	if t.Guard != nil {
+		if err := t.Guard(nft, in); err != nil {
+			return err
+		}
+	}
+	nft.NftStatus = t.To.String()
+	if t.Effect != nil {
+		t.Effect(nft, in)
+	}
+	return nil
+}
+
+// Allowed returns the events the actor can fire on the nft now
+func (m *NftMachine) Allowed(nft *VideoMetadata, actor string) []NftEvent {
+	res := []NftEvent{}
+	for _, t := range m.transitions {
+		if t.Role == ROLE_PLATFORM {
+			continue
+		}
+		if m.Check(nft, t.Event, NftInput{Actor: actor}) == nil {
+			res = append(res, t.Event)
+		}
+	}
+	return res
+}
+
+func setPrice(nft *VideoMetadata, in NftInput) {
+	nft.Price, nft.Currency = in.Price, in.Currency
+}
+
+func clearPrice(nft *VideoMetadata, in NftInput) {
+	nft.Price, nft.Currency = Money{}, ""
+}
+
+func changeHands(nft *VideoMetadata, in NftInput) {
+	nft.Owner = in.Owner
+	clearPrice(nft, in)
+}
+
+// NftStateMachine is the lifecycle of a nft: Create -> Mint <-> List/Auction, Mint -> Melt
+var NftStateMachine = NewNftMachine(
+	NftTransition{
+		Event: NFT_MINT, From: []NftStatus{CREATE}, To: MINT, Role: ROLE_CREATOR,
+		Guard: func(nft *VideoMetadata, in NftInput) error {
+			if nft.NftToken != "" {
+				return fmt.Errorf("%w: nft already minted", ERR_ILLEGAL_TRANSITION)
+			}
+			return nil
+		},
+		Effect: func(nft *VideoMetadata, in NftInput) {
+			nft.NftToken = in.Token
+			clearPrice(nft, in)
+		},
+	},
+	NftTransition{
+		Event: NFT_TRANSFER, From: []NftStatus{MINT}, To: MINT, Role: ROLE_OWNER,
+		Guard: func(nft *VideoMetadata, in NftInput) error {
+			if in.Owner == nft.Owner {
+				return errors.New("cannot transfer your nft to yourself")
+			}
+			return nil
+		},
+		Effect: changeHands,
+	},
+	NftTransition{Event: NFT_LIST, From: []NftStatus{MINT}, To: LIST, Role: ROLE_OWNER, Effect: setPrice},
+	NftTransition{Event: NFT_UNLIST, From: []NftStatus{LIST}, To: MINT, Role: ROLE_OWNER, Effect: clearPrice},
+	NftTransition{Event: NFT_REPRICE, From: []NftStatus{LIST}, To: LIST, Role: ROLE_OWNER, Effect: setPrice},
+	NftTransition{Event: NFT_PURCHASE, From: []NftStatus{LIST}, To: MINT, Role: ROLE_BUYER, Effect: changeHands},
+	NftTransition{Event: NFT_MELT, From: []NftStatus{MINT}, To: MELT, Role: ROLE_OWNER, Effect: clearPrice},
+	NftTransition{Event: NFT_AUCTION, From: []NftStatus{MINT}, To: AUCTION, Role: ROLE_OWNER, Effect: setPrice},
+	NftTransition{Event: NFT_AWARD, From: []NftStatus{AUCTION}, To: LIST, Role: ROLE_PLATFORM, Effect: setPrice},
+	NftTransition{Event: NFT_RELEASE, From: []NftStatus{LIST, AUCTION}, To: MINT, Role: ROLE_PLATFORM, Effect: clearPrice},
+)
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNftStateMachine(t *testing.T) {
+	require := require.New(t)
+	m := NftStateMachine
+	nft := &VideoMetadata{FileHash: "file-hash", Creator: "creator", Owner: "creator", NftStatus: CREATE.String()}
+
+	require.ErrorIs(m.Check(nft, NFT_MINT, NftInput{Actor: "someone"}), ERR_NOT_PERMITTED)
+	require.ErrorIs(m.Check(nft, NFT_LIST, NftInput{Actor: "creator"}), ERR_ILLEGAL_TRANSITION)
+	require.Equal([]NftEvent{NFT_MINT}, m.Allowed(nft, "creator"))
+	require.Empty(m.Allowed(nft, "someone"))
+
+	require.NoError(m.Fire(nft, NFT_MINT, NftInput{Token: "file-hash"}))
+	require.Equal(MINT.String(), nft.NftStatus)
+	require.Equal("file-hash", nft.NftToken)
+	// minted only once
+	require.ErrorIs(m.Fire(nft, NFT_MINT, NftInput{Token: "file-hash"}), ERR_ILLEGAL_TRANSITION)
+	require.Equal([]NftEvent{NFT_TRANSFER, NFT_LIST, NFT_MELT, NFT_AUCTION}, m.Allowed(nft, "creator"))
+
+	require.NoError(m.Fire(nft, NFT_LIST, NftInput{Price: mustMoney(t, "1.5")}))
+	require.Equal(LIST.String(), nft.NftStatus)
+	require.Equal("1.5", nft.Price.String())
+	require.Equal([]NftEvent{NFT_UNLIST, NFT_REPRICE}, m.Allowed(nft, "creator"))
+	require.Equal([]NftEvent{NFT_PURCHASE}, m.Allowed(nft, "buyer"))
+	require.ErrorIs(m.Check(nft, NFT_MELT, NftInput{Actor: "creator"}), ERR_ILLEGAL_TRANSITION)
+
+	require.NoError(m.Fire(nft, NFT_PURCHASE, NftInput{Actor: "buyer", Owner: "buyer"}))
+	require.Equal(MINT.String(), nft.NftStatus)
+	require.Equal("buyer", nft.Owner)
+	require.True(nft.Price.IsNull())
+	// a second purchase of the same listing
+	require.ErrorIs(m.Fire(nft, NFT_PURCHASE, NftInput{Actor: "another", Owner: "another"}), ERR_ILLEGAL_TRANSITION)
+
+	require.Error(m.Check(nft, NFT_TRANSFER, NftInput{Actor: "buyer", Owner: "buyer"}))
+	require.NoError(m.Fire(nft, NFT_AUCTION, NftInput{Price: mustMoney(t, "1")}))
+	require.Empty(m.Allowed(nft, "buyer"))
+	require.NoError(m.Fire(nft, NFT_RELEASE, NftInput{}))
+	require.NoError(m.Fire(nft, NFT_MELT, NftInput{}))
+	require.Equal(MELT.String(), nft.NftStatus)
+	require.Empty(m.Allowed(nft, "buyer"))
+}
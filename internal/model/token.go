@@ -0,0 +1,38 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// Token is a token of a limited edition video, it's owned and sold apart from the other
+// tokens of the video. A 1/1 video has no token row, the video metadata is the token.
+type Token struct {
+	Id        int64  `gorm:"primary_key;auto_increment" json:"-"`
+	TokenId   string `gorm:"size:160;unique;not null" json:"tokenId"`
+	FileHash  string `gorm:"size:128;index;not null" json:"fileHash"`
+	Edition   uint32 `gorm:"not null" json:"edition"`
+	Owner     string `gorm:"size:64;index;not null" json:"owner"`
+	NftStatus string `json:"nftStatus"`
+	Price     Money  `gorm:"type:decimal(40,0);default:NULL" json:"price"`
+	Currency  string `gorm:"size:16;not null;default:''" json:"currency"`
+	Version   int64  `gorm:"not null;default:0" json:"-"`
+}
+
+func (t *Token) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+// Take loads the token by the token id
+func (t *Token) Take(db *gorm.DB) error {
+	return db.Where("token_id = ?", t.TokenId).Take(t).Error
+}
+
+// Update saves the changed token if it's not changed by others since it was loaded
+func (t *Token) Update(db *gorm.DB) error {
+	return updateVersioned(db, t, &t.Version, "owner", "nft_status", "price", "currency")
+}
+
+func QueryTokens(db *gorm.DB, filehash string) (res []Token, err error) {
+	err = db.Where("file_hash = ?", filehash).Order("edition").Find(&res).Error
+	return
+}
@@ -23,6 +23,7 @@ const (
 	MINT
 	LIST
 	MELT
+	AUCTION
 )
 const (
 	NULL          = "--"
@@ -30,26 +31,38 @@ const (
 )
 
 type VideoMetadata struct {
-	Id           int64  `gorm:"primary_key;auto_increment" json:"-"`
-	FileName     string `gorm:"not null;" json:"fileName"`
-	FileHash     string `gorm:"not null;unique;" json:"fileHash"`
-	Description  string `gorm:"type:text;not null;" json:"description"`
-	CoverImg     string `json:"coverImg"`
-	Length       string `json:"length"`
-	Views        int64  `json:"views"`
-	Label        string `json:"label"`
-	Size         int64  `gorm:"not null" json:"size"`
-	FileStatus   string `json:"fileStatus"`
-	Creator      string `gorm:"not null" json:"creator"`
-	Owner        string `gorm:"not null" json:"owner"`
-	NftToken     string `gorm:"unique;default:NULL" json:"nftToken"`
-	Price        string `gorm:"default:NULL" json:"price"`
+	Id          int64  `gorm:"primary_key;auto_increment" json:"-"`
+	FileName    string `gorm:"not null;" json:"fileName"`
+	FileHash    string `gorm:"not null;unique;" json:"fileHash"`
+	Description string `gorm:"type:text;not null;" json:"description"`
+	CoverImg    string `json:"coverImg"`
+	Length      string `json:"length"`
+	Views       int64  `json:"views"`
+	Label       string `json:"label"`
+	Size        int64  `gorm:"not null" json:"size"`
+	FileStatus  string `json:"fileStatus"`
+	Creator     string `gorm:"not null" json:"creator"`
+	Owner       string `gorm:"not null" json:"owner"`
+	NftToken    string `gorm:"unique;default:NULL" json:"nftToken"`
+	Price       Money  `gorm:"type:decimal(40,0);default:NULL" json:"price"`
+	// the asset the price is in, empty for the chain token
+	Currency     string `gorm:"size:16;not null;default:''" json:"currency"`
 	NftStatus    string `json:"nftStatus"`
 	Chain        string `json:"chain"`
 	ContractAddr string `gorm:"default:NULL" json:"contractAddr"`
 	NftType      string `gorm:"size:16" json:"nftType"`
+	// percentage of the secondary sales paid to the creator
+	Royalty uint32 `gorm:"not null;default:0" json:"royalty"`
+	// number of tokens of a limited edition, 1 for a 1/1 nft
+	Editions uint32 `gorm:"not null;default:1" json:"editions"`
+	// the collection the video belongs to, 0 for none
+	CollectionId int64 `gorm:"index;not null;default:0" json:"collectionId"`
+	// bumped on every change of the nft, a change made on a stale row is rejected
+	Version int64 `gorm:"not null;default:0" json:"-"`
 }
 
+var ERR_VERSION_CONFLICT = errors.New("the nft has been changed by another operation, please retry")
+
 func (t FileStatus) String() string {
 	switch t {
 	case UPLOAD:
@@ -74,6 +87,8 @@ func (t NftStatus) String() string {
 		return "List"
 	case MELT:
 		return "Melt"
+	case AUCTION:
+		return "Auction"
 	}
 	return "Unknow"
 }
@@ -96,8 +111,40 @@ func (t *VideoMetadata) IsExist(db *gorm.DB) (bool, error) {
 	return count >= 1, tx.Error
 }
 
+// Update saves the nft columns if the nft is not changed by others since it was loaded
 func (t *VideoMetadata) Update(db *gorm.DB) error {
-	tx := db.Updates(t)
+	columns := []string{"owner", "nft_status", "price", "currency"}
+	if t.NftToken != "" {
+		columns = append(columns, "nft_token")
+	}
+	return updateVersioned(db, t, &t.Version, columns...)
+}
+
+// AddViews counts a view of the video
+func (t *VideoMetadata) AddViews(db *gorm.DB) error {
+	return db.Model(&VideoMetadata{}).Where("file_hash = ?", t.FileHash).
+		UpdateColumn("views", gorm.Expr("views + 1")).Error
+}
+
+// UpdateFileStatus changes the file status alone, the nft columns are left alone
+func (t *VideoMetadata) UpdateFileStatus(db *gorm.DB, status string) error {
+	t.FileStatus = status
+	return db.Model(&VideoMetadata{}).Where("file_hash = ?", t.FileHash).
+		UpdateColumn("file_status", status).Error
+}
+
+// updateVersioned saves the columns of the row on condition its version is still the loaded one,
+// the version is bumped. ERR_VERSION_CONFLICT if the row has been changed since.
+func updateVersioned(db *gorm.DB, row any, version *int64, columns ...string) error {
+	loaded := *version
+	*version = loaded + 1
+	tx := db.Model(row).Where("version = ?", loaded).Select(append(columns, "version")).Updates(row)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Error = ERR_VERSION_CONFLICT
+	}
+	if tx.Error != nil {
+		*version = loaded
+	}
 	return tx.Error
 }
 
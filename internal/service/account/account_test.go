@@ -0,0 +1,84 @@
+package account
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBalance(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	as := &AccountService{}
+
+	res, err := as.QueryBalance(bob.Address)
+	require.NoError(err)
+	require.Equal("0", res.Free)
+	_, _, err = fc.TransferBySs58Address(bob.Address, big.NewInt(2))
+	require.NoError(err)
+	res, err = as.QueryBalance(bob.Address)
+	require.NoError(err)
+	require.Equal("2", res.Free)
+	require.Equal("2", res.Transferable)
+
+	_, err = as.QueryBalance("not-an-address")
+	require.Error(err)
+}
+
+func TestSignWithSeed(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	bob, err := signature.KeyringPairFromSecret("//Bob", 11330)
+	require.NoError(err)
+	call, err := fc.NewCall(chain.CALL_NFT_BURN, types.NewBytes([]byte("file-hash")))
+	require.NoError(err)
+	unsigned, err := codec.EncodeToHex(types.NewExtrinsic(call))
+	require.NoError(err)
+
+	// signed with the next nonce of the account
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		res, err := signWithSeed("//Bob", unsigned)
+		require.NoError(err)
+		ext, _, err := chain.DecodeSignedTx(res.Extrinsic)
+		require.NoError(err)
+		require.True(ext.IsSigned())
+		require.Equal(bob.PublicKey, ext.Signature.Signer.AsID[:])
+		require.Equal(nonce, (*big.Int)(&ext.Signature.Nonce).Uint64())
+		_, err = fc.SendTx1(res.Extrinsic)
+		require.NoError(err)
+	}
+
+	_, err = signWithSeed("//Bob", "not-an-extrinsic")
+	require.Error(err)
+}
+
+func TestVerifyDotWalletSign(t *testing.T) {
+	require := require.New(t)
+	kr, err := signature.KeyringPairFromSecret("//Bob", 11330)
+	require.NoError(err)
+	ts := time.Now().Unix()
+	sig, err := signature.Sign([]byte("<Bytes>"+kr.Address+strconv.FormatInt(ts, 10)+"</Bytes>"), kr.URI)
+	require.NoError(err)
+	require.NoError(VerifyDotWalletSign(kr.Address, ts, hex.EncodeToString(sig)))
+
+	// signed for another time or by another wallet
+	require.Error(VerifyDotWalletSign(kr.Address, ts+1, hex.EncodeToString(sig)))
+	alice := signature.TestKeyringPairAlice
+	require.Error(VerifyDotWalletSign(alice.Address, ts, hex.EncodeToString(sig)))
+	// out of the time window
+	require.Error(VerifyDotWalletSign(kr.Address, ts-60, hex.EncodeToString(sig)))
+}
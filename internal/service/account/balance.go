@@ -0,0 +1,56 @@
+package account
+
+import (
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/paging"
+
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+	"gorm.io/gorm"
+)
+
+// QueryBalance returns the balance of the wallet formatted in units of token
+func (t *AccountService) QueryBalance(walletAddress string) (*dto.BalanceResp, error) {
+	_, pubkey, err := subkey.SS58Decode(walletAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid wallet address")
+	}
+	balance, err := ctx.ChainClient.GetAccountBalance(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	decimals := ctx.ChainClient.TokenDecimals()
+	return &dto.BalanceResp{
+		WalletAddress: walletAddress,
+		Free:          chain.FormatBalance(balance.Free, decimals),
+		Reserved:      chain.FormatBalance(balance.Reserved, decimals),
+		Frozen:        chain.FormatBalance(balance.Frozen, decimals),
+		Transferable:  chain.FormatBalance(balance.Transferable(), decimals),
+		Nonce:         balance.Nonce,
+	}, nil
+}
+
+// QueryTransfers pages the balance transfers from or to the wallet indexed from the chain events
+func (t *AccountService) QueryTransfers(walletAddress string, pr paging.PageRequest) (paging.PagingResulter, error) {
+	db := t.gorm.Model(&model.Activity{}).
+		Where("event_type = ? AND (source = ? OR target = ?)", model.ACT_BT.String(), walletAddress, walletAddress)
+	return paging.MapList(pr, db.Session(&gorm.Session{}).Order("id DESC"), db.Session(&gorm.Session{}),
+		func(act model.Activity) dto.TransferResp {
+			direction := dto.TRANSFER_IN
+			if act.Source == walletAddress {
+				direction = dto.TRANSFER_OUT
+			}
+			return dto.TransferResp{
+				TxHash:    act.TxHash,
+				From:      act.Source,
+				To:        act.Target,
+				Amount:    act.Price.String(),
+				Fee:       act.Gas,
+				Direction: direction,
+				Date:      act.EndDate,
+			}
+		})
+}
@@ -13,48 +13,56 @@ import (
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
 )
 
-func (t *AccountService) SignTx(req *dto.SignTxReq) (string, error) {
+func (t *AccountService) SignTx(req *dto.SignTxReq) (*dto.SignTxResp, error) {
 	logger.Info("sign tx request", "req", req)
 	acc, err := t.FetchByWalletAddress(req.WalletAddress)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if acc == nil {
-		return "", errors.New("the wallet account not exist")
+		return nil, errors.New("the wallet account not exist")
 	}
 	if acc.Kind != entity.AK_EMAIL_GEN {
-		return "", errors.New("only sign tx for email wallet")
+		return nil, errors.New("only sign tx for email wallet")
 	}
 	if acc.Seed == nil || len(*acc.Seed) == 0 {
-		return "", errors.New("the seed of the email wallet has been lost")
+		return nil, errors.New("the seed of the email wallet has been lost")
 	}
+	return signWithSeed(*acc.Seed, req.Extrinsic)
+}
 
+// signWithSeed signs the extrinsic, given in json or hex, by the keyring of the seed with the next nonce
+func signWithSeed(seed, extrinsic string) (*dto.SignTxResp, error) {
 	var ext types.Extrinsic
-	if ext.UnmarshalJSON([]byte(req.Extrinsic)) != nil {
-		bytes, _ := json.Marshal(req.Extrinsic)
+	if ext.UnmarshalJSON([]byte(extrinsic)) != nil {
+		bytes, _ := json.Marshal(extrinsic)
 		if err := ext.UnmarshalJSON(bytes); err != nil {
 			logger.Error(err, "")
-			return "", err
+			return nil, err
 		}
 	}
 	logger.Info("", "extrinsic", ext)
-	keyring, err := signature.KeyringPairFromSecret(*acc.Seed, 11330)
+	keyring, err := signature.KeyringPairFromSecret(seed, 11330)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := ctx.ChainClient.AccountNextIndex(keyring.PublicKey)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	nonce, err := ctx.ChainClient.GetAccountNonce(keyring.PublicKey)
+	options, window, err := ctx.ChainClient.MakeSignatureOptions(nonce)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	// Sign the transaction
-	err = ext.Sign(keyring, ctx.ChainClient.MakeSignatureOptions(nonce))
+	err = ext.Sign(keyring, options)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	data, err := codec.EncodeToHex(ext)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return data, nil
+	return &dto.SignTxResp{Extrinsic: data, EraBirth: window.Birth, EraDeath: window.Death}, nil
 }
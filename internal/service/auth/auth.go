@@ -1,12 +1,11 @@
 package auth
 
 import (
-	"math/big"
 	"time"
-	"vdo-platform/internal/app/ctx"
 	"vdo-platform/internal/dto"
 	"vdo-platform/internal/service/account"
 	ae "vdo-platform/internal/service/account/entity"
+	"vdo-platform/internal/service/faucet"
 	"vdo-platform/pkg/setting"
 	"vdo-platform/pkg/utils"
 
@@ -62,7 +61,7 @@ func ApplyAuthCode(req dto.EmailAuthCodeReq) error {
 	return sendAuthCodeEmail(smtpSetting, authCode, req.Email)
 }
 
-func LoginByEmail(req dto.EmailLoginReq) (*LoginResult, error) {
+func LoginByEmail(req dto.EmailLoginReq, clientIp string) (*LoginResult, error) {
 	logger.V(1).Info("", "req", req)
 	if req.AuthCode != "666888" { //FIXME: MUST REMOVE THIS IN PRODUCTION ENV!
 		if ac, ok := authCodeMap[req.Email]; ok {
@@ -90,11 +89,7 @@ func LoginByEmail(req dto.EmailLoginReq) (*LoginResult, error) {
 			return nil, err
 		}
 		logger.Info("email account wallet address", "walletAddress", account.WalletAddress)
-		go func() {
-			amount := big.NewInt(10000)
-			logger.Info("give money to wallet", "walletAddress", account.WalletAddress, "amount", amount, "email", account.Email)
-			ctx.ChainClient.TransferBySs58Address(account.WalletAddress, amount)
-		}()
+		grantFaucet(account.WalletAddress, clientIp)
 	}
 	return generateLoginResult(account)
 }
@@ -117,7 +112,7 @@ func LoginByDotWallet(req dto.DotWalletLoginReq) (*LoginResult, error) {
 	return generateLoginResult(account)
 }
 
-func LoginByEthWallet(req dto.EthWalletLoginReq) (*LoginResult, error) {
+func LoginByEthWallet(req dto.EthWalletLoginReq, clientIp string) (*LoginResult, error) {
 	_, err := account.VerifyEthWalletSign(req.EthAddress, req.DotAddress, req.Timestamp, req.Sign)
 	if err != nil {
 		return nil, err
@@ -132,15 +127,21 @@ func LoginByEthWallet(req dto.EthWalletLoginReq) (*LoginResult, error) {
 		if err != nil {
 			return nil, err
 		}
-		go func() {
-			amount := big.NewInt(10000)
-			logger.Info("give money to wallet", "dotWalletAddress", account.WalletAddress, "ethWalletAddress", req.EthAddress, "amount", amount)
-			ctx.ChainClient.TransferBySs58Address(account.WalletAddress, amount)
-		}()
+		grantFaucet(account.WalletAddress, clientIp)
 	}
 	return generateLoginResult(account)
 }
 
+// grantFaucet gives tokens to the new account, the login goes on whether it's granted or not
+func grantFaucet(walletAddress, clientIp string) {
+	grant, err := faucet.Grant(walletAddress, clientIp)
+	if err != nil {
+		logger.Info("no faucet grant for the new account", "walletAddress", walletAddress, "reason", err.Error())
+		return
+	}
+	logger.Info("give money to wallet", "walletAddress", walletAddress, "amount", grant.Amount, "grantId", grant.Id)
+}
+
 func generateLoginResult(account *ae.Account) (*LoginResult, error) {
 	token, expiredTime, err := jwtHelper.GenerateToken(*account)
 	if err != nil {
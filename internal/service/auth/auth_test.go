@@ -8,6 +8,16 @@ import (
 	"testing"
 	"time"
 
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	ae "vdo-platform/internal/service/account/entity"
+	"vdo-platform/internal/service/faucet"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/setting"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/vedhavyas/go-subkey/v2"
 	"github.com/vedhavyas/go-subkey/v2/sr25519"
@@ -46,3 +56,66 @@ func TestSign1(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, kr.Verify(msg, s))
 }
+
+func setupOffline() {
+	Setup(&setting.Settings{AppSetting: &setting.AppSettingS{JwtSecret: "secret", JwtDuration: 60, OutputAuthCode: true}}, nil, logr.Discard())
+}
+
+func TestLoginResultToken(t *testing.T) {
+	setupOffline()
+	key := jwtHelper.JwtKey
+	t.Cleanup(func() { jwtHelper.JwtKey = key })
+	res, err := generateLoginResult(&ae.Account{WalletAddress: "cXhA1Tp6ypNApG2menDRGtjixXkabugAcgCqE1EyAekAU6Aew", Kind: ae.AK_PRIVATE_DOT})
+	assert.NoError(t, err)
+	claims, err := ParseJwtToken(res.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, res.WalletAddress, claims.WalletAddress)
+	assert.Equal(t, ae.AK_PRIVATE_DOT, claims.AccountKind)
+
+	// signed with another secret
+	jwtHelper.JwtKey = []byte("another")
+	_, err = ParseJwtToken(res.Token)
+	assert.Error(t, err)
+}
+
+// the new account is granted tokens on chain once, the login goes on either way
+func TestLoginFaucetGrant(t *testing.T) {
+	setupOffline()
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	ledger := faucet.SetupOffline(setting.FaucetSettingS{Amount: 10, PerAccountLimit: 1}, logr.Discard())
+	address := "cXhA1Tp6ypNApG2menDRGtjixXkabugAcgCqE1EyAekAU6Aew"
+	_, pubkey, err := subkey.SS58Decode(address)
+	assert.NoError(t, err)
+
+	grantFaucet(address, "1.1.1.1")
+	assert.Eventually(t, func() bool { return fc.Balance(pubkey).String() == "10000000000000" }, time.Second, 10*time.Millisecond)
+	grantFaucet(address, "1.1.1.1")
+	list := ledger.Grants()
+	assert.Len(t, list, 2)
+	assert.Equal(t, model.GRANT_REJECTED.String(), list[1].State)
+	assert.Equal(t, "10000000000000", fc.Balance(pubkey).String())
+}
+
+// the logins are rejected before the account is looked up
+func TestLoginRejected(t *testing.T) {
+	setupOffline()
+	kr, err := sr25519.Scheme{}.FromPhrase("barely aim fringe nest flush peace settle base inhale phrase vote brand", "")
+	assert.NoError(t, err)
+	ts := time.Now().Unix()
+	s, err := kr.Sign([]byte("<Bytes>" + kr.SS58Address(11330) + strconv.FormatInt(ts, 10) + "</Bytes>"))
+	assert.NoError(t, err)
+	_, err = LoginByDotWallet(dto.DotWalletLoginReq{Address: kr.SS58Address(11330), Timestamp: ts + 1, Sign: hex.EncodeToString(s)})
+	assert.Error(t, err)
+
+	_, err = LoginByEmail(dto.EmailLoginReq{Email: "someone@example.com", AuthCode: "123456"}, "")
+	assert.Error(t, err)
+	assert.NoError(t, ApplyAuthCode(dto.EmailAuthCodeReq{Email: "someone@example.com"}))
+	code := authCodeMap["someone@example.com"].Code
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	_, err = LoginByEmail(dto.EmailLoginReq{Email: "someone@example.com", AuthCode: wrong}, "")
+	assert.EqualError(t, err, "incorrect auth-code")
+}
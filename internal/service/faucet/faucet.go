@@ -0,0 +1,409 @@
+package faucet
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/paging"
+	"vdo-platform/pkg/setting"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// the wait before a transfer is retried or looked up again, shortened by the tests
+var RETRY_BACKOFF = 10 * time.Second
+
+var (
+	ERR_FAUCET_OFF        = errors.New("the faucet is switched off")
+	ERR_ACCOUNT_LIMIT     = errors.New("the account has reached the grant limit")
+	ERR_IP_DAILY_LIMIT    = errors.New("the client ip has reached the daily grant limit")
+	ERR_DAILY_LIMIT       = errors.New("the faucet has reached the daily grant limit")
+	ERR_BUDGET_EXHAUSTED  = errors.New("the faucet budget is exhausted")
+	ERR_GRANT_NOT_REDRIVE = errors.New("only the failed grants can be re-driven")
+	ERR_TRANSFER_PENDING  = errors.New("the transfer of the grant may still be included")
+)
+
+var (
+	logger  logr.Logger
+	conf    setting.FaucetSettingS
+	enabled atomic.Bool
+	// serializes the limit checks and the ledger writes of new grants
+	grantLock sync.Mutex
+	grants    ledger = dbLedger{}
+)
+
+// grantFilter narrows the counted grants down, the empty fields don't filter
+type grantFilter struct {
+	WalletAddress string
+	ClientIp      string
+	Since         time.Time
+}
+
+// ledger keeps the grants the limits and the budget are checked against
+type ledger interface {
+	// counted is the number of the grants counted in the limits
+	counted(f grantFilter) (int64, error)
+	// granted is the amount of the grants counted in the budget
+	granted() (int64, error)
+	take(id int64) (*model.FaucetGrant, error)
+	save(grant *model.FaucetGrant) error
+}
+
+// dbLedger is the ledger in the database
+type dbLedger struct{}
+
+func (dbLedger) counted(f grantFilter) (int64, error) {
+	db := ctx.GormDb
+	if f.WalletAddress != "" {
+		db = db.Where("wallet_address = ?", f.WalletAddress)
+	}
+	if f.ClientIp != "" {
+		db = db.Where("client_ip = ?", f.ClientIp)
+	}
+	if !f.Since.IsZero() {
+		db = db.Where("created_at >= ?", f.Since)
+	}
+	return model.CountFaucetGrants(db, "state IN ?", countedStates())
+}
+
+func (dbLedger) granted() (int64, error) {
+	return model.SumFaucetGrantAmount(ctx.GormDb, "state IN ?", countedStates())
+}
+
+func (dbLedger) take(id int64) (*model.FaucetGrant, error) {
+	grant := &model.FaucetGrant{Id: id}
+	return grant, grant.Take(ctx.GormDb)
+}
+
+func (dbLedger) save(grant *model.FaucetGrant) error {
+	if grant.Id == 0 {
+		return grant.Create(ctx.GormDb)
+	}
+	return grant.Update(ctx.GormDb)
+}
+
+func Setup(settings *setting.Settings, lg logr.Logger) {
+	logger = lg.WithName("faucet")
+	if settings.FaucetSetting != nil {
+		conf = *settings.FaucetSetting
+	}
+	enabled.Store(conf.Enabled && conf.Amount > 0)
+	interruptPendingGrants()
+}
+
+// interruptPendingGrants fails the grants left pending by the last run, their transfers
+// may have been submitted, so they are left for the admin to check and re-drive.
+func interruptPendingGrants() {
+	err := ctx.GormDb.Model(&model.FaucetGrant{}).
+		Where("state = ?", model.GRANT_PENDING.String()).
+		Updates(map[string]any{"state": model.GRANT_FAILED.String(), "last_error": "interrupted by restart"}).Error
+	if err != nil {
+		logger.Error(err, "fail the interrupted grants error")
+	}
+}
+
+func IsEnabled() bool {
+	return enabled.Load()
+}
+
+// Switch turns the faucet on or off at runtime
+func Switch(on bool) {
+	enabled.Store(on && conf.Amount > 0)
+	logger.Info("faucet switched", "enabled", enabled.Load())
+}
+
+type Status struct {
+	Enabled     bool  `json:"enabled"`
+	Amount      int64 `json:"amount"`
+	Budget      int64 `json:"budget"`
+	Granted     int64 `json:"granted"`
+	GrantsToday int64 `json:"grantsToday"`
+}
+
+func QueryStatus() (*Status, error) {
+	granted, err := grants.granted()
+	if err != nil {
+		return nil, err
+	}
+	today, err := grants.counted(grantFilter{Since: startOfToday()})
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		Enabled:     IsEnabled(),
+		Amount:      conf.Amount,
+		Budget:      conf.Budget,
+		Granted:     granted,
+		GrantsToday: today,
+	}, nil
+}
+
+// the grants counted in the limits and the budget
+func countedStates() []string {
+	return []string{model.GRANT_PENDING.String(), model.GRANT_SUCCESS.String()}
+}
+
+func startOfToday() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Grant gives tokens to the new account in the background if the limits allow,
+// the rejected grants are recorded in the ledger as well.
+func Grant(walletAddress, clientIp string) (*model.FaucetGrant, error) {
+	if !IsEnabled() {
+		return nil, ERR_FAUCET_OFF
+	}
+	grantLock.Lock()
+	defer grantLock.Unlock()
+	grant := &model.FaucetGrant{
+		WalletAddress: walletAddress,
+		ClientIp:      clientIp,
+		Amount:        conf.Amount,
+		State:         model.GRANT_PENDING.String(),
+	}
+	reason, err := checkLimits(grant)
+	if err != nil {
+		return nil, err
+	}
+	if reason != nil {
+		grant.State = model.GRANT_REJECTED.String()
+		grant.LastError = reason.Error()
+	}
+	if err := grants.save(grant); err != nil {
+		return nil, errors.Wrap(err, "record faucet grant error")
+	}
+	if reason != nil {
+		logger.Info("grant rejected", "walletAddress", walletAddress, "clientIp", clientIp, "reason", reason.Error())
+		return grant, reason
+	}
+	go drive(grant)
+	return grant, nil
+}
+
+// checkLimits returns the reason to reject the grant, err is for the failed queries
+func checkLimits(grant *model.FaucetGrant) (reason error, err error) {
+	if conf.PerAccountLimit > 0 {
+		n, err := grants.counted(grantFilter{WalletAddress: grant.WalletAddress})
+		if err != nil {
+			return nil, err
+		}
+		if n >= conf.PerAccountLimit {
+			return ERR_ACCOUNT_LIMIT, nil
+		}
+	}
+	today := startOfToday()
+	if conf.PerIpDailyLimit > 0 && grant.ClientIp != "" {
+		n, err := grants.counted(grantFilter{ClientIp: grant.ClientIp, Since: today})
+		if err != nil {
+			return nil, err
+		}
+		if n >= conf.PerIpDailyLimit {
+			return ERR_IP_DAILY_LIMIT, nil
+		}
+	}
+	if conf.DailyLimit > 0 {
+		n, err := grants.counted(grantFilter{Since: today})
+		if err != nil {
+			return nil, err
+		}
+		if n >= conf.DailyLimit {
+			return ERR_DAILY_LIMIT, nil
+		}
+	}
+	return checkBudget(grant.Amount)
+}
+
+func checkBudget(amount int64) (reason error, err error) {
+	if conf.Budget <= 0 {
+		return nil, nil
+	}
+	granted, err := grants.granted()
+	if err != nil {
+		return nil, err
+	}
+	if granted+amount > conf.Budget {
+		// the kill-switch, it's up to the admin to switch the faucet on again after the budget is raised
+		Switch(false)
+		return ERR_BUDGET_EXHAUSTED, nil
+	}
+	return nil, nil
+}
+
+// drive transfers the grant until it succeeds or runs out of retries, a submitted transfer
+// is only sent again once it's known not to be included, so the grant is never paid twice.
+func drive(grant *model.FaucetGrant) {
+	logger := logger.WithValues("grantId", grant.Id, "walletAddress", grant.WalletAddress, "amount", grant.Amount)
+	for {
+		err := settle(grant)
+		if err == nil {
+			grant.State = model.GRANT_SUCCESS.String()
+			grant.LastError = ""
+			saveGrant(grant)
+			logger.Info("grant transferred", "txHash", grant.TxHash)
+			return
+		}
+		grant.LastError = err.Error()
+		if errors.Is(err, ERR_TRANSFER_PENDING) {
+			// not a failure, wait until the transfer is included or its era expires
+			saveGrant(grant)
+			time.Sleep(RETRY_BACKOFF)
+			continue
+		}
+		var de *chain.DispatchError
+		if errors.As(err, &de) || grant.Retries >= conf.MaxRetries {
+			grant.State = model.GRANT_FAILED.String()
+			saveGrant(grant)
+			logger.Error(err, "grant failed")
+			return
+		}
+		grant.Retries++
+		saveGrant(grant)
+		logger.Error(err, "grant transfer error, retry later", "retries", grant.Retries)
+		time.Sleep(RETRY_BACKOFF * time.Duration(grant.Retries))
+	}
+}
+
+// settle looks up the submitted transfer of the grant, the transfer is only sent
+// again if it was dropped or its era expired without it being included
+func settle(grant *model.FaucetGrant) error {
+	if grant.TxHash != "" {
+		res, err := lookupTransfer(grant)
+		if err != nil {
+			return err
+		}
+		switch res.Status {
+		case chain.TX_IN_BLOCK, chain.TX_FINALIZED:
+			return nil
+		case chain.TX_FAILED:
+			return res.Err
+		}
+		if res.BestNumber < grant.EraDeath {
+			return errors.Wrapf(ERR_TRANSFER_PENDING, "txHash: %s, era death: %d", grant.TxHash, grant.EraDeath)
+		}
+		grant.TxHash, grant.EraBirth, grant.EraDeath = "", 0, 0
+	}
+	return transfer(grant)
+}
+
+// transfer submits the transfer of the grant, the tx hash is kept unless
+// the transfer is known to be out of the pool
+func transfer(grant *model.FaucetGrant) error {
+	// the transfer is signed no earlier than this window
+	_, window, err := ctx.ChainClient.MakeSignatureOptions(0)
+	if err != nil {
+		return errors.Wrap(err, "make signature options error")
+	}
+	_, txHash, err := ctx.ChainClient.TransferBySs58Address(grant.WalletAddress, big.NewInt(grant.Amount))
+	if txHash == nil || errors.Is(err, chain.ERR_TX_NOT_INCLUDED) {
+		return err
+	}
+	grant.TxHash = txHash.Hex()
+	grant.EraBirth = window.Birth
+	return err
+}
+
+// lookupTransfer finds the submitted transfer of the grant on chain, the era death is taken
+// from the current window the first time, which can't end before the one the transfer was signed in.
+func lookupTransfer(grant *model.FaucetGrant) (*chain.TxResult, error) {
+	txHash, err := types.NewHashFromHexString(grant.TxHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tx hash of grant")
+	}
+	if grant.EraDeath == 0 {
+		_, window, err := ctx.ChainClient.MakeSignatureOptions(0)
+		if err != nil {
+			return nil, errors.Wrap(err, "make signature options error")
+		}
+		grant.EraDeath = window.Death
+	}
+	after := grant.EraBirth
+	if after > 0 {
+		after--
+	}
+	res, err := ctx.ChainClient.QueryTx(txHash, after)
+	if err != nil {
+		return nil, errors.Wrap(err, "query transfer error")
+	}
+	return res, nil
+}
+
+func saveGrant(grant *model.FaucetGrant) {
+	if err := grants.save(grant); err != nil {
+		logger.Error(err, "update faucet grant error", "grantId", grant.Id)
+	}
+}
+
+// Redrive transfers a failed grant again, the budget is checked as for a new grant.
+// The transfer submitted before is looked up first, the grant paid by it ends in success
+// and the one whose transfer may still be included is refused with ERR_TRANSFER_PENDING.
+func Redrive(id int64) (*model.FaucetGrant, error) {
+	grantLock.Lock()
+	defer grantLock.Unlock()
+	grant, err := grants.take(id)
+	if err != nil {
+		return nil, err
+	}
+	if grant.State != model.GRANT_FAILED.String() {
+		return nil, ERR_GRANT_NOT_REDRIVE
+	}
+	if grant.TxHash != "" {
+		res, err := lookupTransfer(grant)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case res.Status == chain.TX_IN_BLOCK || res.Status == chain.TX_FINALIZED:
+			// paid by the transfer submitted before
+			grant.State = model.GRANT_SUCCESS.String()
+			grant.LastError = ""
+			return grant, grants.save(grant)
+		case res.Status == chain.TX_PENDING && res.BestNumber < grant.EraDeath:
+			if err := grants.save(grant); err != nil {
+				return nil, err
+			}
+			return nil, errors.Wrapf(ERR_TRANSFER_PENDING, "txHash: %s, era death: %d", grant.TxHash, grant.EraDeath)
+		}
+		grant.TxHash, grant.EraBirth, grant.EraDeath = "", 0, 0
+	}
+	reason, err := checkBudget(grant.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if reason != nil {
+		return nil, reason
+	}
+	grant.State = model.GRANT_PENDING.String()
+	grant.Retries = 0
+	if err := grants.save(grant); err != nil {
+		return nil, err
+	}
+	go drive(grant)
+	return grant, nil
+}
+
+type GrantQuery struct {
+	paging.PageRequest
+	State         string `json:"state" form:"state"`
+	WalletAddress string `json:"walletAddress" form:"walletAddress"`
+}
+
+func QueryGrants(q GrantQuery) (paging.PagingResulter, error) {
+	db := ctx.GormDb.Model(&model.FaucetGrant{})
+	if q.State != "" {
+		db = db.Where("state = ?", q.State)
+	}
+	if q.WalletAddress != "" {
+		db = db.Where("wallet_address = ?", q.WalletAddress)
+	}
+	var list []model.FaucetGrant
+	return paging.QueryByPaging(q, &list, db.Session(&gorm.Session{}).Order("id DESC"), db.Session(&gorm.Session{}))
+}
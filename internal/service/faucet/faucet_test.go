@@ -0,0 +1,182 @@
+package faucet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/setting"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func (t *MemLedger) state(id int64) string {
+	g, _ := t.take(id)
+	return g.State
+}
+
+// lostChain loses the result of the transfers accepted by the chain, as a watch timeout would
+type lostChain struct {
+	*chain.FakeChain
+}
+
+func (t lostChain) TransferBySs58Address(target string, amount *big.Int) (*types.Hash, *types.Hash, error) {
+	_, txHash, err := t.FakeChain.TransferBySs58Address(target, amount)
+	if err != nil {
+		return nil, txHash, err
+	}
+	return nil, txHash, errors.New("watch extrinsic timeout")
+}
+
+func setupFaucet(t *testing.T, c setting.FaucetSettingS, existing ...model.FaucetGrant) (*MemLedger, *chain.FakeChain) {
+	l := SetupOffline(c, logr.Discard())
+	for i := range existing {
+		require.NoError(t, l.save(&existing[i]))
+	}
+	backoff := RETRY_BACKOFF
+	RETRY_BACKOFF = 10 * time.Millisecond
+	t.Cleanup(func() { grants, RETRY_BACKOFF = dbLedger{}, backoff })
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	return l, fc
+}
+
+func TestCheckLimits(t *testing.T) {
+	yesterday := startOfToday().Add(-time.Hour)
+	success, failed := model.GRANT_SUCCESS.String(), model.GRANT_FAILED.String()
+	cases := []struct {
+		name     string
+		conf     setting.FaucetSettingS
+		existing []model.FaucetGrant
+		ip       string
+		want     error
+	}{
+		{"no limit", setting.FaucetSettingS{Amount: 10},
+			[]model.FaucetGrant{{WalletAddress: "a", Amount: 10, State: success}}, "1.1.1.1", nil},
+		{"account cap", setting.FaucetSettingS{Amount: 10, PerAccountLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "a", Amount: 10, State: success, CreatedAt: yesterday}}, "", ERR_ACCOUNT_LIMIT},
+		{"failed grants are not counted", setting.FaucetSettingS{Amount: 10, PerAccountLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "a", Amount: 10, State: failed}}, "", nil},
+		{"ip cap", setting.FaucetSettingS{Amount: 10, PerIpDailyLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "b", ClientIp: "1.1.1.1", Amount: 10, State: success}}, "1.1.1.1", ERR_IP_DAILY_LIMIT},
+		{"ip cap of another day", setting.FaucetSettingS{Amount: 10, PerIpDailyLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "b", ClientIp: "1.1.1.1", Amount: 10, State: success, CreatedAt: yesterday}}, "1.1.1.1", nil},
+		{"ip cap without ip", setting.FaucetSettingS{Amount: 10, PerIpDailyLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "b", Amount: 10, State: success}}, "", nil},
+		{"daily cap", setting.FaucetSettingS{Amount: 10, DailyLimit: 1},
+			[]model.FaucetGrant{{WalletAddress: "b", ClientIp: "2.2.2.2", Amount: 10, State: model.GRANT_PENDING.String()}}, "1.1.1.1", ERR_DAILY_LIMIT},
+		{"budget", setting.FaucetSettingS{Amount: 10, Budget: 25},
+			[]model.FaucetGrant{{WalletAddress: "b", Amount: 10, State: success}}, "", nil},
+		{"budget exhausted", setting.FaucetSettingS{Amount: 10, Budget: 25},
+			[]model.FaucetGrant{{WalletAddress: "b", Amount: 10, State: success}, {WalletAddress: "c", Amount: 10, State: success, CreatedAt: yesterday}}, "", ERR_BUDGET_EXHAUSTED},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupFaucet(t, c.conf, c.existing...)
+			reason, err := checkLimits(&model.FaucetGrant{WalletAddress: "a", ClientIp: c.ip, Amount: c.conf.Amount})
+			require.NoError(t, err)
+			require.Equal(t, c.want, reason)
+			// the faucet is switched off once the budget runs out
+			require.Equal(t, c.want != ERR_BUDGET_EXHAUSTED, IsEnabled())
+		})
+	}
+}
+
+func TestGrant(t *testing.T) {
+	require := require.New(t)
+	l, fc := setupFaucet(t, setting.FaucetSettingS{Amount: 10, PerAccountLimit: 1})
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+
+	grant, err := Grant(bob.Address, "1.1.1.1")
+	require.NoError(err)
+	require.Eventually(func() bool { return l.state(grant.Id) == model.GRANT_SUCCESS.String() }, time.Second, 10*time.Millisecond)
+	require.Equal("10000000000000", fc.Balance(bob.PublicKey).String())
+
+	// the rejected grant is recorded too
+	grant, err = Grant(bob.Address, "1.1.1.1")
+	require.ErrorIs(err, ERR_ACCOUNT_LIMIT)
+	require.Equal(model.GRANT_REJECTED.String(), l.state(grant.Id))
+
+	Switch(false)
+	_, err = Grant(bob.Address, "1.1.1.1")
+	require.ErrorIs(err, ERR_FAUCET_OFF)
+}
+
+func TestRedrive(t *testing.T) {
+	require := require.New(t)
+	l, fc := setupFaucet(t, setting.FaucetSettingS{Amount: 10, Budget: 20})
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+
+	// the transfer failed on chain isn't retried
+	fc.Dispatch = func(ext *types.Extrinsic) ([]*parser.Event, error) {
+		return nil, errors.New("insufficient balance")
+	}
+	grant, err := Grant(bob.Address, "")
+	require.NoError(err)
+	require.Eventually(func() bool { return l.state(grant.Id) == model.GRANT_FAILED.String() }, time.Second, 10*time.Millisecond)
+
+	// only the failed grants are re-driven
+	fc.Dispatch = nil
+	_, err = Redrive(grant.Id)
+	require.NoError(err)
+	require.Eventually(func() bool { return l.state(grant.Id) == model.GRANT_SUCCESS.String() }, time.Second, 10*time.Millisecond)
+	_, err = Redrive(grant.Id)
+	require.ErrorIs(err, ERR_GRANT_NOT_REDRIVE)
+
+	// the re-driven grant is checked against the budget
+	require.NoError(l.save(&model.FaucetGrant{WalletAddress: "c", Amount: 10, State: model.GRANT_SUCCESS.String()}))
+	failed := &model.FaucetGrant{WalletAddress: "d", Amount: 10, State: model.GRANT_FAILED.String()}
+	require.NoError(l.save(failed))
+	_, err = Redrive(failed.Id)
+	require.ErrorIs(err, ERR_BUDGET_EXHAUSTED)
+	require.Equal(model.GRANT_FAILED.String(), l.state(failed.Id))
+}
+
+func TestSubmittedTransferNotPaidTwice(t *testing.T) {
+	require := require.New(t)
+	l, fc := setupFaucet(t, setting.FaucetSettingS{Amount: 10, MaxRetries: 3})
+	ctx.ChainClient = lostChain{fc}
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+
+	// the transfer is found on chain instead of being sent again
+	grant, err := Grant(bob.Address, "")
+	require.NoError(err)
+	require.Eventually(func() bool { return l.state(grant.Id) == model.GRANT_SUCCESS.String() }, time.Second, 10*time.Millisecond)
+	require.Equal("10000000000000", fc.Balance(bob.PublicKey).String())
+
+	// the grant interrupted after its transfer is included isn't re-driven
+	ctx.ChainClient = fc
+	g, err := l.take(grant.Id)
+	require.NoError(err)
+	g.State, g.LastError = model.GRANT_FAILED.String(), "interrupted by restart"
+	require.NoError(l.save(g))
+	g, err = Redrive(grant.Id)
+	require.NoError(err)
+	require.Equal(model.GRANT_SUCCESS.String(), g.State)
+	require.Equal("10000000000000", fc.Balance(bob.PublicKey).String())
+
+	// the transfer never included is only sent again once its era expires
+	lost := &model.FaucetGrant{WalletAddress: bob.Address, Amount: 10, State: model.GRANT_FAILED.String(),
+		TxHash: types.NewHash([]byte("lost")).Hex(), EraBirth: 1, EraDeath: 100}
+	require.NoError(l.save(lost))
+	_, err = Redrive(lost.Id)
+	require.ErrorIs(err, ERR_TRANSFER_PENDING)
+	require.Equal(model.GRANT_FAILED.String(), l.state(lost.Id))
+	lost.EraDeath = 1
+	require.NoError(l.save(lost))
+	_, err = Redrive(lost.Id)
+	require.NoError(err)
+	require.Eventually(func() bool { return l.state(lost.Id) == model.GRANT_SUCCESS.String() }, time.Second, 10*time.Millisecond)
+	require.Equal("20000000000000", fc.Balance(bob.PublicKey).String())
+}
@@ -0,0 +1,99 @@
+package faucet
+
+import (
+	"sync"
+	"time"
+
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/setting"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+)
+
+// MemLedger keeps the grants in memory, the saved grants are copied as the database would
+type MemLedger struct {
+	lock sync.Mutex
+	list []model.FaucetGrant
+}
+
+// SetupOffline runs the faucet against a ledger in memory, so the services granting
+// tokens can be tested without a database
+func SetupOffline(c setting.FaucetSettingS, lg logr.Logger) *MemLedger {
+	logger = lg.WithName("faucet")
+	conf = c
+	enabled.Store(conf.Amount > 0)
+	l := &MemLedger{}
+	grants = l
+	return l
+}
+
+// Grants returns a copy of the grants in the ledger
+func (t *MemLedger) Grants() []model.FaucetGrant {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]model.FaucetGrant(nil), t.list...)
+}
+
+func (t *MemLedger) counted(f grantFilter) (int64, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var n int64
+	for _, g := range t.list {
+		if !isCounted(g.State) {
+			continue
+		}
+		if (f.WalletAddress != "" && g.WalletAddress != f.WalletAddress) ||
+			(f.ClientIp != "" && g.ClientIp != f.ClientIp) || g.CreatedAt.Before(f.Since) {
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (t *MemLedger) granted() (int64, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var sum int64
+	for _, g := range t.list {
+		if isCounted(g.State) {
+			sum += g.Amount
+		}
+	}
+	return sum, nil
+}
+
+func isCounted(state string) bool {
+	for _, s := range countedStates() {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *MemLedger) take(id int64) (*model.FaucetGrant, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if id <= 0 || int(id) > len(t.list) {
+		return nil, errors.New("record not found")
+	}
+	g := t.list[id-1]
+	return &g, nil
+}
+
+func (t *MemLedger) save(grant *model.FaucetGrant) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if grant.Id == 0 {
+		if grant.CreatedAt.IsZero() {
+			grant.CreatedAt = time.Now()
+		}
+		grant.Id = int64(len(t.list) + 1)
+		t.list = append(t.list, *grant)
+		return nil
+	}
+	t.list[grant.Id-1] = *grant
+	return nil
+}
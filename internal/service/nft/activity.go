@@ -25,18 +25,22 @@ func QueryActivitiesByQuerier(querier dto.Querier) (dto.Responder, error) {
 	}
 	video := &model.VideoMetadata{}
 	for _, v := range list {
-		if v.EventType == model.ACT_FPG.String() {
+		if v.EventType == model.ACT_FPG.String() ||
+			v.EventType == model.ACT_BT.String() {
 			continue
 		}
 		r := dto.EventResp{
 			EventType: v.EventType,
 			FileHash:  v.FileHash,
-			Price:     v.Price,
+			Token:     v.NftToken,
+			Fiat:      fiatValue(v.Price, v.Currency),
 			State:     v.State,
 			Date:      v.EndDate,
 			From:      v.Source,
 			To:        v.Target,
+			Gas:       v.Gas,
 		}
+		r.Price, r.Currency = model.FormatPrice(v.Price, v.Currency)
 		if v.EventType != model.ACT_TS.String() &&
 			v.EventType != model.ACT_TX.String() {
 			r.From = model.NULL
@@ -60,6 +64,41 @@ func QueryActivitiesByQuerier(querier dto.Querier) (dto.Responder, error) {
 	return responder, nil
 }
 
+// QueryActivity returns a single activity, it's used to poll the state of a tracked tx
+func QueryActivity(id int64) (dto.EventResp, error) {
+	act := &model.Activity{Id: id}
+	acts, err := act.Get(ctx.GormDb)
+	if err != nil {
+		return dto.EventResp{}, errors.Wrap(err, "query activity error")
+	}
+	if len(acts) <= 0 {
+		return dto.EventResp{}, errors.New("activity not found")
+	}
+	return toEventResp(&acts[0]), nil
+}
+
+func toEventResp(act *model.Activity) dto.EventResp {
+	date := act.EndDate
+	if date == "" {
+		date = act.StartDate
+	}
+	r := dto.EventResp{
+		ActivityId: act.Id,
+		EventType:  getEventType(*act),
+		FileHash:   act.FileHash,
+		Token:      act.NftToken,
+		From:       act.Source,
+		To:         act.Target,
+		Fiat:       fiatValue(act.Price, act.Currency),
+		State:      act.State,
+		TxHash:     act.TxHash,
+		Gas:        act.Gas,
+		Date:       date,
+	}
+	r.Price, r.Currency = model.FormatPrice(act.Price, act.Currency)
+	return r
+}
+
 func getEventType(act model.Activity) string {
 	eventType := ""
 	switch act.EventType {
@@ -68,6 +107,10 @@ func getEventType(act model.Activity) string {
 			eventType = "list"
 		} else if strings.ToLower(act.Source) == "list" {
 			eventType = "unlist"
+		} else if strings.ToLower(act.Target) == "auction" {
+			eventType = "auction"
+		} else if strings.ToLower(act.Source) == "auction" {
+			eventType = "auction-end"
 		}
 	case model.ACT_TS.String():
 		eventType = "transfer"
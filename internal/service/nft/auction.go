@@ -0,0 +1,246 @@
+package nft
+
+import (
+	"math/big"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	AUCTION_MIN_DURATION = 10 * time.Minute
+	AUCTION_MAX_DURATION = 30 * 24 * time.Hour
+	// a bid in the last minutes of an auction extends it, so there is always time to outbid
+	AUCTION_EXTEND_WINDOW = 5 * time.Minute
+	// percentage a bid must raise the highest bid by
+	MIN_BID_INCREMENT = 5
+)
+
+var (
+	ERR_AUCTION_CLOSED = errors.New("the auction is closed")
+	ERR_BID_TOO_LOW    = errors.New("the bid is too low")
+)
+
+// OpenAuction puts the minted nft of the owner on an english auction, the bids are in the currency of the reserve price
+func OpenAuction(owner string, req dto.OpenAuctionReq) (*dto.AuctionResp, error) {
+	nft, err := loadItem(ctx.GormDb, req.FileHash, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	reserve, currency, err := parseListingPrice(req.ReservePrice, req.Currency)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid reserve price")
+	}
+	in := model.NftInput{Actor: owner, Price: reserve, Currency: currency.Code()}
+	if err := checkEvent(&nft.VideoMetadata, model.NFT_AUCTION, in); err != nil {
+		return nil, err
+	}
+	endTime := time.Unix(req.EndTime, 0)
+	if d := time.Until(endTime); d < AUCTION_MIN_DURATION || d > AUCTION_MAX_DURATION {
+		return nil, errors.Errorf("the auction must last between %s and %s", AUCTION_MIN_DURATION, AUCTION_MAX_DURATION)
+	}
+	auction := &model.Auction{
+		FileHash:     nft.FileHash,
+		NftToken:     nft.NftToken,
+		Seller:       owner,
+		ReservePrice: reserve,
+		Currency:     in.Currency,
+		State:        model.AUCTION_OPEN.String(),
+		EndTime:      endTime,
+	}
+	err = ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if err := auction.Create(tx); err != nil {
+			return err
+		}
+		if err := marketStatusActivity(tx, &nft.VideoMetadata, model.AUCTION, reserve, in.Currency); err != nil {
+			return err
+		}
+		if err := nft.fire(model.NFT_AUCTION, in); err != nil {
+			return err
+		}
+		return nft.Update(tx)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "open auction error")
+	}
+	return auctionResp(auction, nil), nil
+}
+
+// minNextBid is the least amount the next bid must offer
+func minNextBid(reserve, highest *big.Int) *big.Int {
+	if highest == nil {
+		return reserve
+	}
+	min := percentOf(highest, 100+MIN_BID_INCREMENT)
+	if min.Cmp(highest) <= 0 {
+		min.Add(highest, big.NewInt(1))
+	}
+	return min
+}
+
+// extendedEnd is the end time of the auction after a bid placed at now
+func extendedEnd(end, now time.Time) time.Time {
+	if end.Sub(now) < AUCTION_EXTEND_WINDOW {
+		return now.Add(AUCTION_EXTEND_WINDOW)
+	}
+	return end
+}
+
+// PlaceBid bids on the open auction, the bid must outbid the highest one
+func PlaceBid(auctionId int64, bidder, amount string) (*dto.AuctionResp, error) {
+	marketLock.Lock()
+	defer marketLock.Unlock()
+	auction := &model.Auction{Id: auctionId}
+	if err := auction.Take(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "query auction error")
+	}
+	// the bid is in the currency of the auction
+	bidAmount, currency, err := parseListingPrice(amount, auction.Currency)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid bid amount")
+	}
+	offer := bidAmount.Planck()
+	now := time.Now()
+	if auction.State != model.AUCTION_OPEN.String() || !now.Before(auction.EndTime) {
+		return nil, ERR_AUCTION_CLOSED
+	}
+	if bidder == auction.Seller {
+		return nil, errors.New("unable to bid on your own auction")
+	}
+	if min := minNextBid(auction.ReservePrice.Planck(), auction.HighestBid.Planck()); offer.Cmp(min) < 0 {
+		return nil, errors.Wrapf(ERR_BID_TOO_LOW, "at least %s", listingPrice(model.NewMoney(min), auction.Currency))
+	}
+	if err := checkAffordable(bidder, currency, offer); err != nil {
+		return nil, err
+	}
+	auction.HighestBid = bidAmount
+	auction.HighestBidder = bidder
+	auction.Bids++
+	auction.EndTime = extendedEnd(auction.EndTime, now)
+	err = ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		bid := &model.Bid{AuctionId: auction.Id, Bidder: bidder, Amount: bidAmount}
+		if err := bid.Create(tx); err != nil {
+			return err
+		}
+		act := &model.Activity{
+			EventType: model.ACT_BID.String(),
+			Creator:   bidder,
+			Source:    bidder,
+			Target:    auction.Seller,
+			FileHash:  auction.FileHash,
+			NftToken:  auction.NftToken,
+			Price:     bidAmount,
+			Currency:  auction.Currency,
+			State:     model.SUCCESS.String(),
+			StartDate: now.Local().Format(ctx.Time_FMT),
+			EndDate:   now.Local().Format(ctx.Time_FMT),
+		}
+		if err := act.Create(tx); err != nil {
+			return err
+		}
+		return auction.Update(tx)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "place bid error")
+	}
+	return auctionResp(auction, nil), nil
+}
+
+// QueryAuction returns the auction with its bids, the latest first
+func QueryAuction(auctionId int64) (*dto.AuctionResp, error) {
+	auction := &model.Auction{Id: auctionId}
+	if err := auction.Take(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "query auction error")
+	}
+	bids, err := model.QueryBids(ctx.GormDb, auctionId)
+	if err != nil {
+		return nil, errors.Wrap(err, "query bids error")
+	}
+	return auctionResp(auction, bids), nil
+}
+
+// settleAuctions closes the ended auctions, the nft is listed at the highest bid for the winner
+// to buy if the reserve price is met, otherwise it returns to the mint state. The nft not bought
+// by the winner in time returns to the mint state too, unless the purchase is still tracked.
+func settleAuctions() {
+	marketLock.Lock()
+	defer marketLock.Unlock()
+	now := time.Now()
+	ended, err := model.QueryAuctions(ctx.GormDb, "(state = ? AND end_time <= ?) OR (state = ? AND end_time <= ?)",
+		model.AUCTION_OPEN.String(), now, model.AUCTION_AWARDED.String(), now.Add(-RESERVATION_TIMEOUT))
+	if err != nil {
+		logger.Error(err, "[Auction] query ended auctions error")
+		return
+	}
+	for i := range ended {
+		if ended[i].State == model.AUCTION_AWARDED.String() {
+			err = unclaimAuction(&ended[i])
+		} else {
+			err = settleAuction(&ended[i])
+		}
+		if err != nil {
+			logger.Error(err, "[Auction] settle auction error", "auctionId", ended[i].Id)
+		}
+	}
+}
+
+func settleAuction(auction *model.Auction) error {
+	nft, err := loadItem(ctx.GormDb, auction.FileHash, auction.NftToken)
+	if err != nil {
+		return err
+	}
+	return ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if auction.HighestBidder == "" {
+			auction.State = model.AUCTION_PASSED.String()
+			if err := marketStatusActivity(tx, &nft.VideoMetadata, model.MINT, model.Money{}, ""); err != nil {
+				return err
+			}
+			if err := nft.fire(model.NFT_RELEASE, model.NftInput{}); err != nil {
+				return err
+			}
+		} else {
+			auction.State = model.AUCTION_AWARDED.String()
+			if err := marketStatusActivity(tx, &nft.VideoMetadata, model.LIST, auction.HighestBid, auction.Currency); err != nil {
+				return err
+			}
+			in := model.NftInput{Price: auction.HighestBid, Currency: auction.Currency}
+			if err := nft.fire(model.NFT_AWARD, in); err != nil {
+				return err
+			}
+		}
+		if err := auction.Update(tx); err != nil {
+			return err
+		}
+		return nft.Update(tx)
+	})
+}
+
+// unclaimAuction releases the nft the winner didn't buy in time, the winner is kept with the auction
+func unclaimAuction(auction *model.Auction) error {
+	inFlight, err := txInFlight(auction.NftToken)
+	if err != nil || inFlight {
+		return err
+	}
+	nft, err := loadItem(ctx.GormDb, auction.FileHash, auction.NftToken)
+	if err != nil {
+		return err
+	}
+	return ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		auction.State = model.AUCTION_UNCLAIMED.String()
+		if err := marketStatusActivity(tx, &nft.VideoMetadata, model.MINT, model.Money{}, ""); err != nil {
+			return err
+		}
+		if err := nft.fire(model.NFT_RELEASE, model.NftInput{}); err != nil {
+			return err
+		}
+		if err := auction.Update(tx); err != nil {
+			return err
+		}
+		return nft.Update(tx)
+	})
+}
@@ -0,0 +1,27 @@
+package nft
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinNextBid(t *testing.T) {
+	require := require.New(t)
+	reserve := big.NewInt(1000)
+	require.Equal(reserve, minNextBid(reserve, nil))
+	require.Equal(big.NewInt(2100), minNextBid(reserve, big.NewInt(2000)))
+	// the increment never rounds down to nothing
+	require.Equal(big.NewInt(11), minNextBid(reserve, big.NewInt(10)))
+}
+
+func TestExtendedEnd(t *testing.T) {
+	require := require.New(t)
+	now := time.Now()
+	end := now.Add(time.Hour)
+	require.Equal(end, extendedEnd(end, now))
+	end = now.Add(time.Minute)
+	require.Equal(now.Add(AUCTION_EXTEND_WINDOW), extendedEnd(end, now))
+}
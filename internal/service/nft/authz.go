@@ -0,0 +1,114 @@
+package nft
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+)
+
+type Operation string
+
+const (
+	OP_CREATE   Operation = "create"
+	OP_DELETE   Operation = "delete"
+	OP_MINT     Operation = "mint"
+	OP_TRANSFER Operation = "transfer"
+	OP_LIST     Operation = "list"
+	OP_REPRICE  Operation = "reprice"
+	OP_MELT     Operation = "melt"
+	OP_AUCTION  Operation = "auction"
+	OP_PURCHASE Operation = "purchase"
+	OP_OFFER    Operation = "offer"
+)
+
+// ForbiddenError is returned when the actor has no right to the operation
+type ForbiddenError struct {
+	Actor string
+	Op    Operation
+}
+
+func (e *ForbiddenError) Error() string {
+	actor := e.Actor
+	if actor == "" {
+		actor = "anonymous"
+	}
+	if e.Op == OP_OFFER {
+		return fmt.Sprintf("%s is not allowed to answer the offer on the nft", actor)
+	}
+	return fmt.Sprintf("%s is not allowed to %s the nft", actor, e.Op)
+}
+
+func (e *ForbiddenError) HttpStatusHint() int {
+	return http.StatusForbidden
+}
+
+// authorize checks the actor, the wallet address in the login claims, has the right to the operation.
+// The creator manages the video until it's minted, the owner manages the nft after.
+func authorize(actor string, op Operation, nft *model.VideoMetadata) error {
+	holder := nft.Owner
+	switch op {
+	case OP_DELETE, OP_MINT:
+		holder = nft.Creator
+	}
+	if actor == "" || actor != holder {
+		return &ForbiddenError{Actor: actor, Op: op}
+	}
+	return nil
+}
+
+// checkEvent checks the actor of the input can fire the event on the nft now,
+// the actor lacking the role of the event gets a ForbiddenError
+func checkEvent(nft *model.VideoMetadata, evt model.NftEvent, in model.NftInput) error {
+	err := model.NftStateMachine.Check(nft, evt, in)
+	if errors.Is(err, model.ERR_NOT_PERMITTED) {
+		return &ForbiddenError{Actor: in.Actor, Op: Operation(evt)}
+	}
+	return err
+}
+
+// fireEvent moves the nft by the event, the transitions are logged for audit
+func fireEvent(nft *model.VideoMetadata, evt model.NftEvent, in model.NftInput) error {
+	from := nft.NftStatus
+	if err := model.NftStateMachine.Fire(nft, evt, in); err != nil {
+		return err
+	}
+	logger.Info("[Nft state] transition", "fileHash", nft.FileHash, "token", nft.NftToken,
+		"event", evt, "from", from, "to", nft.NftStatus, "actor", in.Actor, "owner", nft.Owner, "price", nft.Price)
+	return nil
+}
+
+// authorizeSelf checks the actor acts on behalf of itself, the account defaults to the actor
+func authorizeSelf(actor string, op Operation, account string) error {
+	if actor == "" || (account != "" && account != actor) {
+		return &ForbiddenError{Actor: actor, Op: op}
+	}
+	return nil
+}
+
+// AllowedActions returns what the actor can do on the nft now, the nft reserved for
+// a buyer can only be bought by the buyer
+func AllowedActions(filehash, token, actor string) (*dto.NftActionsResp, error) {
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.NftActionsResp{FileHash: nft.FileHash, Token: nft.NftToken, Status: nft.NftStatus, Actions: []string{}}
+	if nft.NftStatus == model.CREATE.String() && authorize(actor, OP_DELETE, &nft.VideoMetadata) == nil {
+		res.Actions = append(res.Actions, string(OP_DELETE))
+	}
+	reserved, err := reservedBuyer(nft.NftToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, evt := range model.NftStateMachine.Allowed(&nft.VideoMetadata, actor) {
+		if reserved != "" && (evt != model.NFT_PURCHASE || actor != reserved) {
+			continue
+		}
+		res.Actions = append(res.Actions, string(evt))
+	}
+	return res, nil
+}
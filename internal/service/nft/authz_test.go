@@ -0,0 +1,52 @@
+package nft
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+
+	"vdo-platform/internal/model"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	require := require.New(t)
+	nft := &model.VideoMetadata{Creator: "creator", Owner: "owner"}
+	require.NoError(authorize("creator", OP_MINT, nft))
+	require.NoError(authorize("creator", OP_DELETE, nft))
+	require.NoError(authorize("owner", OP_TRANSFER, nft))
+	require.NoError(authorize("owner", OP_LIST, nft))
+
+	// the creator has no right to the nft sold to the owner
+	err := errors.Wrap(authorize("creator", OP_REPRICE, nft), "change price")
+	var forbidden *ForbiddenError
+	require.ErrorAs(err, &forbidden)
+	require.Equal(OP_REPRICE, forbidden.Op)
+	require.Equal(http.StatusForbidden, forbidden.HttpStatusHint())
+	require.ErrorAs(authorize("owner", OP_MINT, nft), &forbidden)
+	require.ErrorAs(authorize("", OP_MELT, &model.VideoMetadata{}), &forbidden)
+
+	require.NoError(authorizeSelf("buyer", OP_PURCHASE, ""))
+	require.NoError(authorizeSelf("buyer", OP_PURCHASE, "buyer"))
+	require.ErrorAs(authorizeSelf("buyer", OP_PURCHASE, "someone"), &forbidden)
+
+	forbidden = &ForbiddenError{Actor: "someone", Op: OP_OFFER}
+	require.Equal("someone is not allowed to answer the offer on the nft", forbidden.Error())
+}
+
+func TestCheckEvent(t *testing.T) {
+	require := require.New(t)
+	nft := &model.VideoMetadata{Creator: "creator", Owner: "owner", NftStatus: model.LIST.String(), NftToken: "file-hash"}
+	require.NoError(checkEvent(nft, model.NFT_REPRICE, model.NftInput{Actor: "owner", Price: model.NewMoney(big.NewInt(2))}))
+	require.NoError(checkEvent(nft, model.NFT_PURCHASE, model.NftInput{Actor: "buyer", Owner: "buyer"}))
+
+	var forbidden *ForbiddenError
+	require.ErrorAs(checkEvent(nft, model.NFT_REPRICE, model.NftInput{Actor: "creator"}), &forbidden)
+	require.Equal(OP_REPRICE, forbidden.Op)
+	require.ErrorAs(checkEvent(nft, model.NFT_PURCHASE, model.NftInput{Actor: "owner", Owner: "owner"}), &forbidden)
+
+	// a listed nft has to be unlisted first
+	require.ErrorIs(checkEvent(nft, model.NFT_TRANSFER, model.NftInput{Actor: "owner", Owner: "buyer"}), model.ERR_ILLEGAL_TRANSITION)
+}
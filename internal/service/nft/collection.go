@@ -0,0 +1,140 @@
+package nft
+
+import (
+	"math/big"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/paging"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// CreateCollection creates a collection of the actor to put its videos in
+func CreateCollection(actor string, req dto.CreateCollectionReq) (*model.Collection, error) {
+	if err := authorizeSelf(actor, OP_CREATE, ""); err != nil {
+		return nil, err
+	}
+	if req.Royalty > MAX_ROYALTY {
+		return nil, errors.Errorf("royalty can't be more than %d%%", MAX_ROYALTY)
+	}
+	collection := &model.Collection{
+		Name:        req.Name,
+		Description: req.Description,
+		Banner:      req.Banner,
+		Creator:     actor,
+		Royalty:     req.Royalty,
+	}
+	if err := collection.Create(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "create collection error")
+	}
+	return collection, nil
+}
+
+// QueryCollections returns the collections of the creator
+func QueryCollections(creator string) ([]model.Collection, error) {
+	res, err := model.QueryCollections(ctx.GormDb, "creator = ?", creator)
+	if err != nil {
+		return nil, errors.Wrap(err, "query collections error")
+	}
+	return res, nil
+}
+
+// QueryCollection returns the collection with the stats of its nfts
+func QueryCollection(id int64) (*dto.CollectionResp, error) {
+	collection := &model.Collection{Id: id}
+	if err := collection.Take(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "query collection error")
+	}
+	var videos []model.VideoMetadata
+	err := ctx.GormDb.Where("collection_id = ? AND nft_status <> ?", id, model.MELT.String()).Find(&videos).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "query collection videos error")
+	}
+	var (
+		tokens     []model.Token
+		filehashes = make([]string, 0, len(videos))
+	)
+	for i := range videos {
+		filehashes = append(filehashes, videos[i].FileHash)
+		if videos[i].NftToken == "" {
+			continue
+		}
+		if !isEdition(&videos[i]) {
+			tokens = append(tokens, singleToken(&videos[i]))
+			continue
+		}
+		editions, err := model.QueryTokens(ctx.GormDb, videos[i].FileHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "query tokens error")
+		}
+		tokens = append(tokens, editions...)
+	}
+	var sales []model.Activity
+	if len(filehashes) > 0 {
+		sales, err = model.QueryNftEvents(ctx.GormDb, "event_type = ? AND state IN ? AND file_hash IN ?",
+			model.ACT_TX.String(), []string{model.SUCCESS.String(), model.FINALIZED.String()}, filehashes)
+		if err != nil {
+			return nil, errors.Wrap(err, "query collection sales error")
+		}
+	}
+	stats, err := collectionStats(tokens, sales)
+	if err != nil {
+		return nil, err
+	}
+	stats.Items = uint(len(videos))
+	return &dto.CollectionResp{Collection: collection, Stats: stats}, nil
+}
+
+// collectionStats sums up the minted tokens and the sales of a collection, the floor price
+// is the lowest listed price and the volume the total price of the sales in the chain token,
+// the prices in an asset are left out.
+func collectionStats(tokens []model.Token, sales []model.Activity) (dto.CollectionStats, error) {
+	var (
+		stats  dto.CollectionStats
+		floor  *big.Int
+		volume = new(big.Int)
+		owners = make(map[string]struct{})
+	)
+	for _, t := range tokens {
+		if t.NftStatus == model.MELT.String() {
+			continue
+		}
+		owners[t.Owner] = struct{}{}
+		if t.NftStatus != model.LIST.String() || t.Currency != "" {
+			continue
+		}
+		if t.Price.IsNull() {
+			return stats, errors.Errorf("listed token %s has no price", t.TokenId)
+		}
+		price := t.Price.Planck()
+		if floor == nil || price.Cmp(floor) < 0 {
+			floor = price
+		}
+	}
+	for _, act := range sales {
+		if act.Currency != "" {
+			continue
+		}
+		if act.Price.IsNull() {
+			return stats, errors.Errorf("sale %d has no price", act.Id)
+		}
+		volume.Add(volume, act.Price.Planck())
+	}
+	if floor != nil {
+		stats.FloorPrice = formatFee(floor)
+	}
+	stats.Volume = formatFee(volume)
+	stats.Sales = uint(len(sales))
+	stats.Owners = uint(len(owners))
+	return stats, nil
+}
+
+// QueryCollectionVideos lists the videos of the collection, the latest first
+func QueryCollectionVideos(id int64, pr paging.PageRequest) (paging.PagingResulter, error) {
+	db := ctx.GormDb.Model(&model.VideoMetadata{}).
+		Where("collection_id = ? AND nft_status <> ?", id, model.MELT.String())
+	return paging.MapList(pr, db.Session(&gorm.Session{}).Order("id DESC"), db.Session(&gorm.Session{}), videoResp)
+}
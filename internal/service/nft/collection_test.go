@@ -0,0 +1,45 @@
+package nft
+
+import (
+	"testing"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionStats(t *testing.T) {
+	require := require.New(t)
+	ctx.ChainClient = chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	tokens := []model.Token{
+		{TokenId: "a", Owner: "alice", NftStatus: model.LIST.String(), Price: mustMoney(t, "2.5")},
+		{TokenId: "b#1", Owner: "bob", NftStatus: model.LIST.String(), Price: mustMoney(t, "1.25")},
+		{TokenId: "b#2", Owner: "alice", NftStatus: model.MINT.String()},
+		{TokenId: "c", Owner: "carol", NftStatus: model.MELT.String()},
+		// the prices in an asset are left out of the floor and the volume
+		{TokenId: "d", Owner: "dave", NftStatus: model.LIST.String(), Price: mustMoney(t, "0.000001"), Currency: "USDT"},
+	}
+	sales := []model.Activity{{Price: mustMoney(t, "1.5")}, {Price: mustMoney(t, "3")}, {Price: mustMoney(t, "9"), Currency: "USDT"}}
+	stats, err := collectionStats(tokens, sales)
+	require.NoError(err)
+	require.Equal("1.25", stats.FloorPrice)
+	require.Equal("4.5", stats.Volume)
+	require.EqualValues(3, stats.Sales)
+	require.EqualValues(3, stats.Owners)
+
+	// nothing listed nor sold
+	stats, err = collectionStats(tokens[2:4], nil)
+	require.NoError(err)
+	require.Empty(stats.FloorPrice)
+	require.Equal("0", stats.Volume)
+	require.EqualValues(1, stats.Owners)
+}
+
+func mustMoney(t *testing.T, s string) model.Money {
+	m, err := model.ParseMoney(s)
+	require.NoError(t, err)
+	return m
+}
@@ -0,0 +1,165 @@
+package nft
+
+import (
+	"fmt"
+	"strings"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	NFT_TYPE_SINGLE  = "single"
+	NFT_TYPE_EDITION = "edition"
+	MAX_EDITIONS     = 100
+)
+
+func isEdition(v *model.VideoMetadata) bool {
+	return v.NftType == NFT_TYPE_EDITION
+}
+
+func editionToken(filehash string, edition uint32) string {
+	return fmt.Sprintf("%s#%d", filehash, edition)
+}
+
+// tokenFileHash is the file hash of the video the token belongs to
+func tokenFileHash(token string) string {
+	filehash, _, _ := strings.Cut(token, "#")
+	return filehash
+}
+
+// editionTokens are the token ids of the video, the file hash is the only token of a 1/1 nft
+func editionTokens(v *model.VideoMetadata) []string {
+	if !isEdition(v) {
+		return []string{v.FileHash}
+	}
+	tokens := make([]string, 0, v.Editions)
+	for i := uint32(1); i <= v.Editions; i++ {
+		tokens = append(tokens, editionToken(v.FileHash, i))
+	}
+	return tokens
+}
+
+// item is the nft a marketplace operation acts on, the video itself for a 1/1 nft
+// or a token of an edition. The owner, status, price and token id of the token are
+// copied onto the embedded metadata, so the operations treat both alike.
+type item struct {
+	model.VideoMetadata
+	token *model.Token
+}
+
+// loadItem loads the nft of the video, the token is required for an edition
+func loadItem(db *gorm.DB, filehash, token string) (*item, error) {
+	v := &model.VideoMetadata{FileHash: filehash}
+	resp, err := v.Get(db)
+	if err != nil || len(resp) != 1 {
+		return nil, errors.New("query nft metadata error")
+	}
+	it := &item{VideoMetadata: resp[0]}
+	if !isEdition(&it.VideoMetadata) {
+		if token != "" && token != it.FileHash {
+			return nil, errors.New("no such token of the nft")
+		}
+		return it, nil
+	}
+	if token == "" {
+		return nil, errors.New("the token of the edition is required")
+	}
+	it.token = &model.Token{TokenId: token}
+	if err := it.token.Take(db); err != nil || it.token.FileHash != filehash {
+		return nil, errors.New("no such token of the nft")
+	}
+	it.Owner = it.token.Owner
+	it.NftStatus = it.token.NftStatus
+	it.Price = it.token.Price
+	it.Currency = it.token.Currency
+	it.NftToken = it.token.TokenId
+	return it, nil
+}
+
+// loadItemByToken loads the nft by the token id, the token id of a 1/1 nft is the file hash
+func loadItemByToken(db *gorm.DB, token string) (*item, error) {
+	return loadItem(db, tokenFileHash(token), token)
+}
+
+// fire moves the nft by the event of the nft state machine
+func (t *item) fire(evt model.NftEvent, in model.NftInput) error {
+	return fireEvent(&t.VideoMetadata, evt, in)
+}
+
+// Update saves the owner, status and price to the token of an edition, or to the video of a 1/1 nft
+func (t *item) Update(db *gorm.DB) error {
+	if t.token == nil {
+		return t.VideoMetadata.Update(db)
+	}
+	t.token.Owner = t.Owner
+	t.token.NftStatus = t.NftStatus
+	t.token.Price = t.Price
+	t.token.Currency = t.Currency
+	return t.token.Update(db)
+}
+
+// mintEdition creates the tokens of the minted edition owned by the creator, the existing ones are kept
+func mintEdition(db *gorm.DB, v *model.VideoMetadata) error {
+	existing, err := model.QueryTokens(db, v.FileHash)
+	if err != nil {
+		return err
+	}
+	minted := make(map[string]struct{}, len(existing))
+	for _, t := range existing {
+		minted[t.TokenId] = struct{}{}
+	}
+	for i, id := range editionTokens(v) {
+		if _, ok := minted[id]; ok {
+			continue
+		}
+		t := &model.Token{
+			TokenId:   id,
+			FileHash:  v.FileHash,
+			Edition:   uint32(i + 1),
+			Owner:     v.Creator,
+			NftStatus: model.MINT.String(),
+		}
+		if err := t.Create(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryTokens returns the tokens of the video, a 1/1 nft has a single token
+func QueryTokens(filehash string) ([]model.Token, error) {
+	v := &model.VideoMetadata{FileHash: filehash}
+	resp, err := v.Get(ctx.GormDb)
+	if err != nil || len(resp) != 1 {
+		return nil, errors.New("query nft metadata error")
+	}
+	v = &resp[0]
+	if !isEdition(v) {
+		if v.NftToken == "" {
+			return []model.Token{}, nil
+		}
+		return []model.Token{singleToken(v)}, nil
+	}
+	tokens, err := model.QueryTokens(ctx.GormDb, filehash)
+	if err != nil {
+		return nil, errors.Wrap(err, "query tokens error")
+	}
+	return tokens, nil
+}
+
+// singleToken is the only token of a minted 1/1 nft
+func singleToken(v *model.VideoMetadata) model.Token {
+	return model.Token{
+		TokenId:   v.NftToken,
+		FileHash:  v.FileHash,
+		Edition:   1,
+		Owner:     v.Owner,
+		NftStatus: v.NftStatus,
+		Price:     v.Price,
+		Currency:  v.Currency,
+	}
+}
@@ -0,0 +1,56 @@
+package nft
+
+import (
+	"testing"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditionTokens(t *testing.T) {
+	require := require.New(t)
+	v := &model.VideoMetadata{FileHash: "file-hash", NftType: NFT_TYPE_SINGLE, Editions: 1}
+	require.Equal([]string{"file-hash"}, editionTokens(v))
+
+	v.NftType, v.Editions = NFT_TYPE_EDITION, 3
+	tokens := editionTokens(v)
+	require.Equal([]string{"file-hash#1", "file-hash#2", "file-hash#3"}, tokens)
+	for _, token := range tokens {
+		require.Equal(v.FileHash, tokenFileHash(token))
+	}
+	require.Equal(v.FileHash, tokenFileHash(v.FileHash))
+}
+
+func TestMintExpectedCall(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	alice := signature.TestKeyringPairAlice
+	signer, err := accountIdArg(alice.Address)
+	require.NoError(err)
+	v := &model.VideoMetadata{FileHash: "file-hash", NftType: NFT_TYPE_EDITION, Editions: 2}
+
+	mintBatch := func(tokens ...string) *types.Extrinsic {
+		var calls []types.Call
+		for _, token := range tokens {
+			call, err := fc.NewCall(chain.CALL_NFT_MINT, tokenArg(token))
+			require.NoError(err)
+			calls = append(calls, call)
+		}
+		call, err := fc.NewCall(chain.CALL_UTILITY_BATCH_ALL, calls)
+		require.NoError(err)
+		return signFakeExtrinsic(t, fc, alice, call)
+	}
+	require.NoError(fc.MatchExpectedCall(mintBatch(editionTokens(v)...), mintExpectedCall(signer, v)))
+	// an edition token left out
+	require.ErrorIs(fc.MatchExpectedCall(mintBatch("file-hash#1"), mintExpectedCall(signer, v)), chain.ERR_TX_CALL_MISMATCH)
+
+	v.NftType, v.Editions = NFT_TYPE_SINGLE, 1
+	ext := signFakeCall(t, fc, alice, chain.CALL_NFT_MINT, tokenArg(v.FileHash))
+	require.NoError(fc.MatchExpectedCall(ext, mintExpectedCall(signer, v)))
+}
@@ -0,0 +1,351 @@
+package nft
+
+import (
+	"math/big"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/internal/service/account/entity"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+	"gorm.io/gorm"
+)
+
+const INDEXER_CURSOR_NAME = "nft-indexer"
+
+// ERR_TX_DIVERGED is a tx the chain executed while its activity was failed by the tracker
+var ERR_TX_DIVERGED = errors.New("the chain executed the tx of a failed activity")
+
+type cursorHeightStore struct {
+	name string
+}
+
+func (t cursorHeightStore) LoadHeight() (uint64, error) {
+	cursor := model.ChainCursor{Name: t.name}
+	err := cursor.Get(ctx.GormDb)
+	return cursor.Height, err
+}
+
+func (t cursorHeightStore) SaveHeight(height uint64) error {
+	cursor := model.ChainCursor{Name: t.name, Height: height}
+	return cursor.Save(ctx.GormDb)
+}
+
+func startIndexer() {
+	follower := ctx.ChainClient.NewBlockFollower(cursorHeightStore{INDEXER_CURSOR_NAME}, indexBlock)
+	go follower.Run()
+}
+
+// indexBlock records the chain events related to the platform into the activity table,
+// the events that can't be decoded are skipped, the database errors make the block processed again.
+func indexBlock(block *chain.FollowedBlock) error {
+	for _, evt := range block.Events {
+		var (
+			act *model.Activity
+			err error
+		)
+		switch evt.Name {
+		case chain.EVT_BALANCES_TRANSFER:
+			act, err = balanceTransferActivity(evt)
+		case chain.EVT_FILEBANK_UPLOAD:
+			err = indexFileEvent(evt, "deal_hash", FILE_STATUS_PENDING)
+		case chain.EVT_FILEBANK_STORAGE_DONE:
+			err = indexFileEvent(evt, "file_hash", FILE_STATUS_ACTIVE)
+		case chain.EVT_FILEBANK_DELETE:
+			err = indexFileEvent(evt, "file_hash", FILE_STATUS_DELETE)
+		case chain.EVT_NFT_MINTED, chain.EVT_NFT_TRANSFERRED, chain.EVT_NFT_LISTED,
+			chain.EVT_NFT_UNLISTED, chain.EVT_NFT_PRICE_UPDATED, chain.EVT_NFT_SOLD, chain.EVT_NFT_BURNED:
+			act, err = nftEventActivity(evt)
+		default:
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "[Nft indexer] decode event error", "event", evt.Name, "block", block.Number)
+			continue
+		}
+		if act == nil {
+			continue
+		}
+		act.BlockHash, act.BlockNumber = block.Hash.Hex(), block.Number
+		var ie *chain.IncludedExtrinsic
+		if txHash := block.ExtrinsicHash(evt); txHash != nil {
+			act.TxHash = txHash.Hex()
+			if fee, ok := chain.ExtrinsicFee(block.Events, evt.Phase.AsApplyExtrinsic); ok {
+				act.Gas = formatFee(fee)
+			}
+			ie = &chain.IncludedExtrinsic{BlockHash: block.Hash, BlockNumber: block.Number,
+				TxHash: *txHash, Index: evt.Phase.AsApplyExtrinsic}
+		}
+		if err := indexActivity(act, ie); err != nil {
+			return errors.Wrapf(err, "index event %s of block %d", evt.Name, block.Number)
+		}
+	}
+	return nil
+}
+
+// indexActivity applies the chain event unless the tracker did it, ie is the extrinsic emitting it, nil if none
+func indexActivity(act *model.Activity, ie *chain.IncludedExtrinsic) error {
+	if act.TxHash != "" {
+		query := &model.Activity{TxHash: act.TxHash, EventType: act.EventType, FileHash: act.FileHash}
+		if act.EventType == model.ACT_BT.String() {
+			// a batch may contain several transfers
+			query.Source, query.Target, query.Price = act.Source, act.Target, act.Price
+		}
+		tracked, err := query.Get(ctx.GormDb)
+		if err != nil {
+			return err
+		}
+		var givenUp, failed *model.Activity
+		for i := range tracked {
+			switch tracked[i].State {
+			case model.DROPPED.String(), model.REORGED.String():
+				givenUp = &tracked[i]
+			case model.FAILED.String():
+				failed = &tracked[i]
+			default:
+				// done by the tracker or still tracked
+				return nil
+			}
+		}
+		if givenUp != nil {
+			// a tx given up by the tracker may be finalized later, the purchase is checked as the tracker would
+			var split *purchaseSplit
+			if givenUp.EventType == model.ACT_TX.String() {
+				if split, err = verifyPurchasePayment(givenUp, ie); err != nil {
+					if errors.Is(err, ERR_EVENTS_UNAVAILABLE) {
+						return err
+					}
+					logger.Error(err, "[Nft indexer] purchase rejected", "activityId", givenUp.Id)
+					finishActivity(givenUp, model.FAILED)
+					return recordDiverged(act, givenUp)
+				}
+			}
+			givenUp.Gas = act.Gas
+			givenUp.BlockHash, givenUp.BlockNumber = act.BlockHash, act.BlockNumber
+			return skipIllegalTransition(act, finalizeActivity(givenUp, split))
+		}
+		if failed != nil {
+			return recordDiverged(act, failed)
+		}
+	}
+	act.State = model.FINALIZED.String()
+	act.StartDate = time.Now().Local().Format(ctx.Time_FMT)
+	act.EndDate = act.StartDate
+	err := ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if act.EventType != model.ACT_BT.String() {
+			if err := applyActivity(tx, act); err != nil {
+				return err
+			}
+		}
+		return act.Create(tx)
+	})
+	return skipIllegalTransition(act, err)
+}
+
+// recordDiverged records the tx the chain executed while the tracker failed its activity, such as for a
+// mismatched call or an underpaid purchase. The nft isn't changed, the divergence is left for the admin to check.
+func recordDiverged(act, failed *model.Activity) error {
+	logger.Error(ERR_TX_DIVERGED, "[Nft indexer] record the tx of the failed activity",
+		"activityId", failed.Id, "eventType", act.EventType, "token", act.NftToken, "txHash", act.TxHash)
+	act.State = model.DIVERGED.String()
+	act.StartDate = time.Now().Local().Format(ctx.Time_FMT)
+	act.EndDate = act.StartDate
+	return act.Create(ctx.GormDb)
+}
+
+// skipIllegalTransition drops the event the nft can't go through in its current status
+// instead of processing the block again and again
+func skipIllegalTransition(act *model.Activity, err error) error {
+	if errors.Is(err, model.ERR_ILLEGAL_TRANSITION) {
+		logger.Error(err, "[Nft indexer] skip the event", "eventType", act.EventType, "token", act.NftToken, "txHash", act.TxHash)
+		return nil
+	}
+	return err
+}
+
+func ss58Address(accountId *types.AccountID) string {
+	return subkey.SS58Encode(accountId[:], ctx.Settings.Web3Setting.ChainId)
+}
+
+func chainMoney(evt *parser.Event, name string) (model.Money, error) {
+	amount, err := chain.EventBalance(evt, name)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return model.NewMoney(amount), nil
+}
+
+// formatFee formats the fee paid in units of token, empty for unknown fee
+func formatFee(fee *big.Int) string {
+	if fee == nil {
+		return ""
+	}
+	return chain.FormatBalance(fee, ctx.ChainClient.TokenDecimals())
+}
+
+// balanceTransferActivity only records the transfers from or to the platform accounts
+func balanceTransferActivity(evt *parser.Event) (*model.Activity, error) {
+	from, err := chain.EventAccountId(evt, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := chain.EventAccountId(evt, "to")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := chainMoney(evt, "amount")
+	if err != nil {
+		return nil, err
+	}
+	act := &model.Activity{
+		EventType: model.ACT_BT.String(),
+		Creator:   ss58Address(from),
+		Source:    ss58Address(from),
+		Target:    ss58Address(to),
+		Price:     amount,
+	}
+	var count int64
+	err = ctx.GormDb.Model(&entity.Account{}).
+		Where("wallet_address IN ?", []string{act.Source, act.Target}).
+		Count(&count).Error
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	return act, nil
+}
+
+func indexFileEvent(evt *parser.Event, field, fileState string) error {
+	hash, err := chain.EventBytes(evt, field)
+	if err != nil || len(hash) == 0 {
+		return err
+	}
+	UpdateVideoStatus(string(hash), fileState)
+	return nil
+}
+
+// nftEventActivity maps the nft pallet event to the activity the platform would have created for it
+func nftEventActivity(evt *parser.Event) (*model.Activity, error) {
+	token, err := chain.EventBytes(evt, "token")
+	if err != nil || len(token) == 0 {
+		return nil, err
+	}
+	var nft *item
+	if evt.Name == chain.EVT_NFT_MINTED {
+		// the tokens of an edition are created once the mint is applied
+		v := &model.VideoMetadata{FileHash: tokenFileHash(string(token))}
+		resp, err := v.Get(ctx.GormDb)
+		if err != nil || len(resp) != 1 {
+			// not a video of the platform
+			return nil, nil
+		}
+		nft = &item{VideoMetadata: resp[0]}
+	} else if nft, err = loadItemByToken(ctx.GormDb, string(token)); err != nil {
+		// not a video of the platform
+		return nil, nil
+	}
+	act := &model.Activity{
+		Creator:  nft.Creator,
+		FileHash: nft.FileHash,
+		NftToken: string(token),
+	}
+	switch evt.Name {
+	case chain.EVT_NFT_MINTED:
+		// the edition is minted in one batch, recorded as a single mint of the video
+		act.NftToken = nft.FileHash
+		owner, err := chain.EventAccountId(evt, "owner")
+		if err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_MINT.String()
+		act.Source = model.NULL
+		act.Target = ss58Address(owner)
+	case chain.EVT_NFT_TRANSFERRED:
+		from, err := chain.EventAccountId(evt, "from")
+		if err != nil {
+			return nil, err
+		}
+		to, err := chain.EventAccountId(evt, "to")
+		if err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_TS.String()
+		act.Creator = ss58Address(from)
+		act.Source = ss58Address(from)
+		act.Target = ss58Address(to)
+	case chain.EVT_NFT_SOLD:
+		seller, err := chain.EventAccountId(evt, "seller")
+		if err != nil {
+			return nil, err
+		}
+		buyer, err := chain.EventAccountId(evt, "buyer")
+		if err != nil {
+			return nil, err
+		}
+		if act.Price, err = chainMoney(evt, "price"); err != nil {
+			return nil, err
+		}
+		if act.Currency, err = eventCurrency(evt, nft.Currency); err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_TX.String()
+		act.Creator = ss58Address(buyer)
+		act.Source = ss58Address(seller)
+		act.Target = ss58Address(buyer)
+	case chain.EVT_NFT_BURNED:
+		owner, err := chain.EventAccountId(evt, "owner")
+		if err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_MELT.String()
+		act.Creator = ss58Address(owner)
+		act.Source = ss58Address(owner)
+		act.Target = model.NULL
+	case chain.EVT_NFT_LISTED:
+		if act.Price, err = chainMoney(evt, "price"); err != nil {
+			return nil, err
+		}
+		if act.Currency, err = eventCurrency(evt, nft.Currency); err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_ALT.String()
+		act.Source = nft.NftStatus
+		act.Target = model.LIST.String()
+	case chain.EVT_NFT_UNLISTED:
+		act.EventType = model.ACT_ALT.String()
+		act.Source = nft.NftStatus
+		act.Target = model.MINT.String()
+	case chain.EVT_NFT_PRICE_UPDATED:
+		if act.Price, err = chainMoney(evt, "price"); err != nil {
+			return nil, err
+		}
+		if act.Currency, err = eventCurrency(evt, nft.Currency); err != nil {
+			return nil, err
+		}
+		act.EventType = model.ACT_ALT.String()
+		act.Source = listingPrice(nft.Price, nft.Currency)
+		act.Target = listingPrice(act.Price, act.Currency)
+	}
+	return act, nil
+}
+
+// eventCurrency is the currency of the asset id of the price event, the event
+// without the field is taken to be in the given currency of the nft
+func eventCurrency(evt *parser.Event, currency string) (string, error) {
+	if _, ok := chain.EventField(evt, "asset_id"); !ok {
+		return currency, nil
+	}
+	assetId, err := chain.EventOptionU32(evt, "asset_id")
+	if err != nil || assetId == nil {
+		return "", err
+	}
+	c, err := model.LookupAsset(*assetId)
+	if err != nil {
+		return "", err
+	}
+	return c.Code(), nil
+}
@@ -0,0 +1,38 @@
+package nft
+
+import (
+	"testing"
+
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventCurrency(t *testing.T) {
+	require := require.New(t)
+	model.AddAsset("USDT", 1984, 6)
+	defer delete(model.Assets, "USDT")
+	priced := func(assetId any) *parser.Event {
+		return &parser.Event{Name: chain.EVT_NFT_SOLD, Fields: registry.DecodedFields{
+			{Name: "asset_id", Value: assetId},
+		}}
+	}
+
+	// the event without the asset id is in the currency of the nft
+	c, err := eventCurrency(&parser.Event{Name: chain.EVT_NFT_SOLD}, "USDT")
+	require.NoError(err)
+	require.Equal("USDT", c)
+	// None is the chain token
+	c, err = eventCurrency(priced(byte(0)), "USDT")
+	require.NoError(err)
+	require.Empty(c)
+	c, err = eventCurrency(priced(registry.DecodedFields{{Value: types.NewU32(1984)}}), "")
+	require.NoError(err)
+	require.Equal("USDT", c)
+	_, err = eventCurrency(priced(registry.DecodedFields{{Value: types.NewU32(1)}}), "")
+	require.ErrorIs(err, model.ERR_UNKNOWN_CURRENCY)
+}
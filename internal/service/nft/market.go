@@ -0,0 +1,154 @@
+package nft
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	MARKET_SWEEP_INTERVAL = 30 * time.Second
+	// the time the winner of an auction or the buyer of an accepted offer has to buy the nft
+	RESERVATION_TIMEOUT = 48 * time.Hour
+)
+
+var ERR_NOT_AFFORDED = errors.New("the balance is not enough to pay the price")
+
+// marketLock serializes the bids, the offers and their settlement
+var marketLock sync.Mutex
+
+func startMarketScheduler() {
+	go func() {
+		for {
+			settleAuctions()
+			expireOffers()
+			time.Sleep(MARKET_SWEEP_INTERVAL)
+		}
+	}()
+}
+
+// checkAffordable checks the wallet can pay the amount of the currency in the smallest unit
+func checkAffordable(walletAddress string, currency model.Currency, amount *big.Int) error {
+	_, pubkey, err := subkey.SS58Decode(walletAddress)
+	if err != nil {
+		return errors.Wrap(err, "invalid wallet address")
+	}
+	var transferable *big.Int
+	if currency.IsNative() {
+		balance, err := ctx.ChainClient.GetAccountBalance(pubkey)
+		if err != nil {
+			return errors.Wrap(err, "query balance error")
+		}
+		transferable = balance.Transferable()
+	} else if transferable, err = ctx.ChainClient.GetAssetBalance(currency.AssetId, pubkey); err != nil {
+		return errors.Wrap(err, "query asset balance error")
+	}
+	if transferable.Cmp(amount) < 0 {
+		return ERR_NOT_AFFORDED
+	}
+	return nil
+}
+
+// marketStatusActivity records the nft status changed by the marketplace without a tx
+func marketStatusActivity(tx *gorm.DB, nft *model.VideoMetadata, status model.NftStatus, price model.Money, currency string) error {
+	act := &model.Activity{
+		EventType: model.ACT_ALT.String(),
+		Creator:   nft.Owner,
+		Source:    nft.NftStatus,
+		Target:    status.String(),
+		FileHash:  nft.FileHash,
+		NftToken:  nft.NftToken,
+		Price:     price,
+		Currency:  currency,
+		State:     model.SUCCESS.String(),
+		StartDate: time.Now().Local().Format(ctx.Time_FMT),
+	}
+	act.EndDate = act.StartDate
+	return act.Create(tx)
+}
+
+// reservedBuyer returns the buyer the nft token is reserved for by an awarded auction
+// or an accepted offer, empty if it's not reserved.
+func reservedBuyer(token string) (string, error) {
+	auctions, err := model.QueryAuctions(ctx.GormDb, "nft_token = ? AND state = ?", token, model.AUCTION_AWARDED.String())
+	if err != nil {
+		return "", errors.Wrap(err, "query auction error")
+	}
+	if len(auctions) > 0 {
+		return auctions[0].HighestBidder, nil
+	}
+	offers, err := model.QueryOffers(ctx.GormDb, "nft_token = ? AND state = ?", token, model.OFFER_ACCEPTED.String())
+	if err != nil {
+		return "", errors.Wrap(err, "query offer error")
+	}
+	if len(offers) > 0 {
+		return offers[0].Buyer, nil
+	}
+	return "", nil
+}
+
+// txInFlight tells if a tx on the nft token is still tracked, such as the purchase of the reserved
+// buyer, the reservation holds until the tracker resolves it
+func txInFlight(token string) (bool, error) {
+	acts, err := model.QueryNftEvents(ctx.GormDb.Limit(1), "nft_token = ? AND state IN ?", token, model.TrackingStates())
+	if err != nil {
+		return false, errors.Wrap(err, "query tracked activities error")
+	}
+	return len(acts) > 0, nil
+}
+
+// checkReservedBuyer only lets the buyer the nft is reserved for buy it
+func checkReservedBuyer(token, buyer string) error {
+	reserved, err := reservedBuyer(token)
+	if err != nil {
+		return err
+	}
+	if reserved != "" && reserved != buyer {
+		return errors.New("the nft is reserved for another buyer")
+	}
+	return nil
+}
+
+// checkNotReserved checks the nft isn't on an auction or reserved for a buyer
+func checkNotReserved(nft *model.VideoMetadata) error {
+	if nft.NftStatus == model.AUCTION.String() {
+		return errors.New("nft is on auction")
+	}
+	reserved, err := reservedBuyer(nft.NftToken)
+	if err != nil {
+		return err
+	}
+	if reserved != "" {
+		return errors.New("the nft is reserved for a buyer")
+	}
+	return nil
+}
+
+// closeReservation completes the auction or the offer the nft is reserved by once it's bought
+func closeReservation(db *gorm.DB, token string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Auction{}).
+			Where("nft_token = ? AND state = ?", token, model.AUCTION_AWARDED.String()).
+			Update("state", model.AUCTION_SETTLED.String()).Error
+		if err != nil {
+			return err
+		}
+		offers, err := model.QueryOffers(tx, "nft_token = ? AND state = ?", token, model.OFFER_ACCEPTED.String())
+		if err != nil {
+			return err
+		}
+		for i := range offers {
+			if err := closeOffer(tx, &offers[i], model.OFFER_COMPLETED, model.SUCCESS); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
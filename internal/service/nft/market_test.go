@@ -0,0 +1,62 @@
+package nft
+
+import (
+	"math/big"
+	"testing"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAffordable(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	require.ErrorIs(checkAffordable(bob.Address, model.NativeCurrency(), big.NewInt(1)), ERR_NOT_AFFORDED)
+
+	// in units of token
+	_, _, err = fc.TransferBySs58Address(bob.Address, big.NewInt(5))
+	require.NoError(err)
+	amount, err := model.ParseMoney("5")
+	require.NoError(err)
+	bid := amount.Planck()
+	require.NoError(checkAffordable(bob.Address, model.NativeCurrency(), bid))
+	require.ErrorIs(checkAffordable(bob.Address, model.NativeCurrency(), bid.Add(bid, big.NewInt(1))), ERR_NOT_AFFORDED)
+}
+
+func TestCheckAffordableAsset(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	model.AddAsset("USDT", 1984, 6)
+	defer delete(model.Assets, "USDT")
+	usdt, err := model.LookupCurrency("USDT")
+	require.NoError(err)
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+
+	// the chain token doesn't pay a price in the asset
+	_, _, err = fc.TransferBySs58Address(bob.Address, big.NewInt(5))
+	require.NoError(err)
+	price, err := usdt.Parse("5")
+	require.NoError(err)
+	require.ErrorIs(checkAffordable(bob.Address, usdt, price.Planck()), ERR_NOT_AFFORDED)
+	require.NoError(fc.MintAsset(1984, bob.PublicKey, price.Planck()))
+	require.NoError(checkAffordable(bob.Address, usdt, price.Planck()))
+
+	// the bids are shown in the currency of the auction
+	bid, err := usdt.Parse("6.5")
+	require.NoError(err)
+	a := &model.Auction{ReservePrice: price, HighestBid: bid, Currency: usdt.Code()}
+	r := auctionResp(a, []model.Bid{{Amount: bid}})
+	require.Equal("5", r.ReservePrice)
+	require.Equal("6.5", r.HighestBid)
+	require.Equal("USDT", r.Currency)
+	require.Equal("6.5", r.BidList[0].Amount)
+}
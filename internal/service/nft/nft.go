@@ -1,18 +1,20 @@
 package nft
 
 import (
-	"strconv"
 	"strings"
 	"time"
 
 	"vdo-platform/internal/app/ctx"
 	"vdo-platform/internal/dto"
 	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
 	"vdo-platform/pkg/log"
 
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/go-logr/logr"
 	"github.com/panjf2000/ants/v2"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 var logger logr.Logger
@@ -22,18 +24,99 @@ func Setup() {
 	fileStateQueryUrl = ctx.Settings.AppSetting.CmpHttpUrl
 	listener = new(StatusListener)
 	initStatusListener(3, 512, listener)
+	txTracker = newTxTracker(512)
+	txTracker.Resume()
+	go txTracker.sweep()
+	startIndexer()
+	startMarketScheduler()
 }
 
 type TxData struct {
 	IsSent bool
 	Data   string
+	// wallet address of the caller, the signer expected for a sent tx
+	Signer string
+}
+
+// bindTx attaches the tx of data to the activity, a signed tx is kept
+// in the activity until the tx tracker has submitted it. The signed tx must
+// be the operation of the activity signed by the caller.
+func bindTx(nftEvent *model.Activity, data TxData) error {
+	var txhash string
+	if data.IsSent {
+		if _, err := types.NewHashFromHexString(data.Data); err != nil {
+			return errors.Wrap(err, "invalid tx hash")
+		}
+		txhash = data.Data
+	} else {
+		ext, hash, err := chain.DecodeSignedTx(data.Data)
+		if err != nil {
+			return err
+		}
+		nftEvent.Signer = data.Signer
+		if err := matchActivityCall(nftEvent, ext); err != nil {
+			return err
+		}
+		txhash = hash.Hex()
+		nftEvent.SignedTx = data.Data
+	}
+	used, err := model.QueryNftEvents(ctx.GormDb, "tx_hash = ? AND state IN ?", txhash, model.TxBoundStates())
+	if err != nil {
+		return err
+	}
+	if len(used) > 0 {
+		return errors.New("the tx hash has already been used")
+	}
+	nftEvent.TxHash = txhash
+	nftEvent.Signer = data.Signer
+	return nil
+}
+
+// recordActivity creates the activity of the operation on the nft and bumps the version of the nft
+// in one transaction, of the concurrent operations on the same nft only the first one is recorded.
+func recordActivity(nft interface{ Update(db *gorm.DB) error }, act *model.Activity) error {
+	return ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if err := act.Create(tx); err != nil {
+			return err
+		}
+		return nft.Update(tx)
+	})
 }
 
 // creator, filename, filehash, description, cover, length string, size int64
-func CreateVideoMetadata(req dto.CreateReq) (dto.CreateResp, error) {
+func CreateVideoMetadata(actor string, req dto.CreateReq) (dto.CreateResp, error) {
+	if err := authorizeSelf(actor, OP_CREATE, req.Creator); err != nil {
+		return dto.CreateResp{}, err
+	}
+	req.Creator = actor
 	if req.Creator == "" || req.FileName == "" || req.CoverImage == "" || req.FileSize <= 0 {
 		return dto.CreateResp{}, errors.New("there are empty video file parameters")
 	}
+	if req.CollectionId != 0 {
+		collection := &model.Collection{Id: req.CollectionId}
+		if err := collection.Take(ctx.GormDb); err != nil {
+			return dto.CreateResp{}, errors.Wrap(err, "query collection error")
+		}
+		if err := authorizeSelf(actor, OP_CREATE, collection.Creator); err != nil {
+			return dto.CreateResp{}, err
+		}
+		if req.Royalty == 0 {
+			req.Royalty = collection.Royalty
+		}
+	}
+	if req.Royalty > MAX_ROYALTY {
+		return dto.CreateResp{}, errors.Errorf("royalty can't be more than %d%%", MAX_ROYALTY)
+	}
+	switch {
+	case req.NftType == NFT_TYPE_EDITION:
+		if req.Editions < 2 || req.Editions > MAX_EDITIONS {
+			return dto.CreateResp{}, errors.Errorf("an edition must have 2 to %d tokens", MAX_EDITIONS)
+		}
+	case req.Editions > 1:
+		return dto.CreateResp{}, errors.New("only an edition can have more than one token")
+	default:
+		req.Editions = 1
+	}
 	//check metadata is exsited
 	v := &model.VideoMetadata{FileHash: req.FileHash}
 	if yes, _ := v.IsExist(ctx.GormDb); yes {
@@ -41,24 +124,22 @@ func CreateVideoMetadata(req dto.CreateReq) (dto.CreateResp, error) {
 	}
 	//create video metadata
 	videoInfo := &model.VideoMetadata{
-		Creator:     req.Creator,
-		FileName:    req.FileName,
-		FileHash:    req.FileHash,
-		Description: req.Description,
-		CoverImg:    req.CoverImage,
-		Length:      req.Length,
-		Size:        req.FileSize,
-		Owner:       req.Creator,
-		Label:       req.Label,
-		Price:       model.NULL,
-		NftStatus:   model.CREATE.String(),
-		FileStatus:  model.UPLOAD.String(),
-		Chain:       model.DEFAULT_CHAIN,
-		NftType:     req.NftType,
-	}
-	err := videoInfo.Create(ctx.GormDb)
-	if err != nil {
-		return dto.CreateResp{}, err
+		Creator:      req.Creator,
+		FileName:     req.FileName,
+		FileHash:     req.FileHash,
+		Description:  req.Description,
+		CoverImg:     req.CoverImage,
+		Length:       req.Length,
+		Size:         req.FileSize,
+		Owner:        req.Creator,
+		Label:        req.Label,
+		NftStatus:    model.CREATE.String(),
+		FileStatus:   model.UPLOAD.String(),
+		Chain:        model.DEFAULT_CHAIN,
+		NftType:      req.NftType,
+		Royalty:      req.Royalty,
+		Editions:     req.Editions,
+		CollectionId: req.CollectionId,
 	}
 	//create video file event
 	videoEvent := model.Activity{
@@ -71,14 +152,6 @@ func CreateVideoMetadata(req dto.CreateReq) (dto.CreateResp, error) {
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 		EndDate:   time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = videoEvent.Create(ctx.GormDb)
-	if err != nil {
-		return dto.CreateResp{}, err
-	}
-	//listen and update file status
-	ants.Submit(func() {
-		GetStatusListener().AddListenItem(NewMockFileStateHandler(req.FileHash))
-	})
 	//create event
 	nftEvent := &model.Activity{
 		EventType: model.ACT_CREATE.String(),
@@ -87,14 +160,25 @@ func CreateVideoMetadata(req dto.CreateReq) (dto.CreateResp, error) {
 		Target:    req.Creator,
 		FileHash:  req.FileHash,
 		State:     model.SUCCESS.String(),
-		Price:     model.NULL,
 		StartDate: videoEvent.StartDate,
 		EndDate:   videoEvent.EndDate,
 	}
-	err = nftEvent.Create(ctx.GormDb)
+	err := ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if err := videoInfo.Create(tx); err != nil {
+			return err
+		}
+		if err := videoEvent.Create(tx); err != nil {
+			return err
+		}
+		return nftEvent.Create(tx)
+	})
 	if err != nil {
 		return dto.CreateResp{}, err
 	}
+	//listen and update file status
+	ants.Submit(func() {
+		GetStatusListener().AddListenItem(NewMockFileStateHandler(req.FileHash))
+	})
 	res := dto.CreateResp{
 		Creator: req.Creator,
 		Date:    videoEvent.EndDate,
@@ -102,14 +186,19 @@ func CreateVideoMetadata(req dto.CreateReq) (dto.CreateResp, error) {
 	return res, nil
 }
 
-func DeleteVideoMetadata(filehash string) error {
+func DeleteVideoMetadata(filehash, actor string) error {
 	//check metadata is exsited
 	v := &model.VideoMetadata{FileHash: filehash}
 	if yes, _ := v.IsExist(ctx.GormDb); !yes {
 		return errors.New("video metadata is not exist")
 	}
-	if res, err := v.Get(ctx.GormDb); err == nil && len(res) > 0 {
-		v = &res[0]
+	res, err := v.Get(ctx.GormDb)
+	if err != nil || len(res) != 1 {
+		return errors.New("query video metadata error")
+	}
+	v = &res[0]
+	if err := authorize(actor, OP_DELETE, v); err != nil {
+		return err
 	}
 	if v.NftStatus != model.CREATE.String() {
 		return errors.New("video nft has been minted")
@@ -117,20 +206,18 @@ func DeleteVideoMetadata(filehash string) error {
 	return v.Delete(ctx.GormDb)
 }
 
-func UpdateForMint(filehash string, data TxData) (dto.EventResp, error) {
-	//check:once only
-	var res dto.EventResp
-	test := &model.VideoMetadata{FileHash: filehash, NftStatus: model.MINT.String()}
-	if yes, err := test.IsExist(ctx.GormDb); yes || err != nil {
-		return res, errors.New("nft already minted")
-	}
+func UpdateForMint(filehash, actor string, data TxData) (dto.EventResp, error) {
 	//query metadata
+	var res dto.EventResp
 	nft := &model.VideoMetadata{FileHash: filehash}
 	resp, err := nft.Get(ctx.GormDb)
 	if err != nil || len(resp) != 1 {
 		return res, errors.New("query nft metadata error")
 	}
 	nft = &resp[0]
+	if err := checkEvent(nft, model.NFT_MINT, model.NftInput{Actor: actor, Token: filehash}); err != nil {
+		return res, err
+	}
 	// if nft.FileStatus != model.STORAGE.String() {
 	// 	return res, errors.New("video source file is pending,please wait a moment and try again")
 	// }
@@ -143,71 +230,41 @@ func UpdateForMint(filehash string, data TxData) (dto.EventResp, error) {
 		FileHash:  filehash,
 		State:     model.LISTENING.String(),
 		NftToken:  filehash,
-		Price:     model.NULL,
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = nftEvent.Create(ctx.GormDb)
-	if err != nil {
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
 		return res, errors.Wrap(err, "create mint activity error")
 	}
 	//listen events
-	// ants.Submit(func(){ zz(filehash, data, nftEvent, nft) })
-	zz(filehash, data, nftEvent, nft)
-
-	nftEvent.EndDate = time.Now().Local().Format(ctx.Time_FMT)
-	res.EventType = nftEvent.EventType
-	res.State = nftEvent.State
-	res.Date = nftEvent.EndDate
-	return res, nil
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func zz(filehash string, data TxData, nftEvent *model.Activity, nft *model.VideoMetadata) {
-	logger.Info("[Nft mint service] entry async listening...")
-	var (
-		txhash string
-		err    error
-	)
-	if data.IsSent {
-		txhash = data.Data
-	} else {
-		txhash, err = ctx.ChainClient.SendTx1(data.Data)
-		if err != nil {
-			nftEvent.State = model.FAILED.String()
-			nftEvent.Update(ctx.GormDb)
-			return
-		}
-	}
-	//tx success,update activity state
-	nftEvent.TxHash = txhash
-	nftEvent.State = model.SUCCESS.String()
-	if nftEvent.Update(ctx.GormDb) != nil {
-		return
-	}
-	//change nft state
-	nft.NftStatus = model.MINT.String()
-	nft.NftToken = filehash
-	//unexpected error,rollback
-	if nft.Update(ctx.GormDb) != nil {
-		nftEvent.State = model.FAILED.String()
-		nftEvent.Update(ctx.GormDb)
-	}
-	logger.Info("[Nft mint service] exit async listening")
-}
-
-func UpdateForPurchase(filehash, to string, data TxData) (dto.EventResp, error) {
+// UpdateForPurchase records the purchase, the nft only changes hands after
+// the tx is finalized with the listed price paid to the seller. The actor can only buy for itself.
+func UpdateForPurchase(filehash, token, actor, to string, data TxData) (dto.EventResp, error) {
 	//query metadata
 	var res dto.EventResp
-	nft := &model.VideoMetadata{FileHash: filehash}
-	resp, err := nft.Get(ctx.GormDb)
-	if err != nil || len(resp) != 1 {
-		return res, errors.New("query nft metadata error")
+	if err := authorizeSelf(actor, OP_PURCHASE, to); err != nil {
+		return res, err
 	}
-	nft = &resp[0]
-	if nft.NftStatus != model.LIST.String() || nft.Price == model.NULL {
-		return res, errors.New("nft not list or price error")
+	to = actor
+	nft, err := listedItem(filehash, token)
+	if err != nil {
+		return res, err
 	}
-	if to == nft.Owner {
-		return res, errors.New("unable to purchase your own nft")
+	if err := checkEvent(&nft.VideoMetadata, model.NFT_PURCHASE, model.NftInput{Actor: to, Owner: to}); err != nil {
+		return res, err
+	}
+	if err := checkReservedBuyer(nft.NftToken, to); err != nil {
+		return res, err
+	}
+	split, err := listedSplit(&nft.VideoMetadata)
+	if err != nil {
+		return res, err
 	}
 	//create activity
 	nftEvent := &model.Activity{
@@ -219,75 +276,33 @@ func UpdateForPurchase(filehash, to string, data TxData) (dto.EventResp, error)
 		State:     model.LISTENING.String(),
 		NftToken:  nft.NftToken,
 		Price:     nft.Price,
+		Currency:  nft.Currency,
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = nftEvent.Create(ctx.GormDb)
-	if err != nil {
+	split.keep(nftEvent)
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
 		return res, errors.Wrap(err, "create purchase activity error")
 	}
-	nftEvent.EndDate = time.Now().Local().Format(ctx.Time_FMT)
-	res.EventType = nftEvent.EventType
-	res.From = nftEvent.Source
-	res.To = nftEvent.Target
-	res.Price = nftEvent.Price
 	//listen events
-	// ants.Submit(func() {
-	// 	aa(filehash, to, data, nftEvent, nft)
-	// })
-	aa(to, data, nftEvent, nft)
-	res.State = nftEvent.State
-	res.Date = nftEvent.EndDate
-	return res, nil
-}
-
-func aa(to string, data TxData, nftEvent *model.Activity, nft *model.VideoMetadata) {
-	logger.Info("[Nft purchase service] entry async listening...")
-	var (
-		txhash string
-		err    error
-	)
-	if data.IsSent {
-		txhash = data.Data
-	} else {
-		txhash, err = ctx.ChainClient.SendTx1(data.Data)
-		if err != nil {
-			nftEvent.State = model.FAILED.String()
-			nftEvent.Update(ctx.GormDb)
-			return
-		}
-	}
-	//tx success,update activity state
-	nftEvent.State = model.SUCCESS.String()
-	nftEvent.TxHash = txhash
-	if nftEvent.Update(ctx.GormDb) != nil {
-		return
-	}
-	//change nft state
-	nft.NftStatus = model.MINT.String()
-	nft.Owner = to
-	nft.Price = model.NULL
-	//unexpected error,rollback
-	if nft.Update(ctx.GormDb) != nil {
-		nftEvent.State = model.FAILED.String()
-		nftEvent.Update(ctx.GormDb)
-	}
-	logger.Info("[Nft purchase service] exit async listening")
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func UpdateForTransfer(filehash, to string, data TxData) (dto.EventResp, error) {
+func UpdateForTransfer(filehash, token, actor, to string, data TxData) (dto.EventResp, error) {
 	//query metadata
 	var res dto.EventResp
-	nft := &model.VideoMetadata{FileHash: filehash}
-	resp, err := nft.Get(ctx.GormDb)
-	if err != nil || len(resp) != 1 {
-		return res, errors.New("query nft metadata error")
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return res, err
 	}
-	nft = &resp[0]
-	if nft.NftStatus != model.MINT.String() {
-		return res, errors.New("nft is not mint state")
+	if err := checkEvent(&nft.VideoMetadata, model.NFT_TRANSFER, model.NftInput{Actor: actor, Owner: to}); err != nil {
+		return res, err
 	}
-	if to == nft.Owner {
-		return res, errors.New("cannot transfer your nft to yourself")
+	if _, err := accountIdArg(to); err != nil {
+		return res, err
 	}
 	//create activity
 	nftEvent := &model.Activity{
@@ -298,86 +313,84 @@ func UpdateForTransfer(filehash, to string, data TxData) (dto.EventResp, error)
 		FileHash:  filehash,
 		State:     model.LISTENING.String(),
 		NftToken:  nft.NftToken,
-		Price:     model.NULL,
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = nftEvent.Create(ctx.GormDb)
-	if err != nil {
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
 		return res, errors.Wrap(err, "create transfer activity error")
 	}
-
-	nftEvent.EndDate = time.Now().Local().Format(ctx.Time_FMT)
-	res.EventType = nftEvent.EventType
-	res.From = nftEvent.Source
-	res.To = nftEvent.Target
 	//listen events
-	// ants.Submit(func() {
-	// 	bb(filehash, to, data, nftEvent, nft)
-	// })
-	bb(to, data, nftEvent, nft)
-	res.State = nftEvent.State
-	res.Date = nftEvent.EndDate
-	return res, nil
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func bb(to string, data TxData, nftEvent *model.Activity, nft *model.VideoMetadata) {
-	logger.Info("[Nft transfer service] entry async listening...")
-	var (
-		txhash string
-		err    error
-	)
-	if data.IsSent {
-		txhash = data.Data
-	} else {
-		txhash, err = ctx.ChainClient.SendTx1(data.Data)
-		if err != nil {
-			nftEvent.State = model.FAILED.String()
-			nftEvent.Update(ctx.GormDb)
-			return
-		}
+// UpdateForMelt burns the nft of the owner, the nft must not be listed or reserved for a buyer
+func UpdateForMelt(filehash, token, owner string, data TxData) (dto.EventResp, error) {
+	//query metadata
+	var res dto.EventResp
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return res, err
 	}
-	//tx success,update activity state
-	nftEvent.State = model.SUCCESS.String()
-	nftEvent.TxHash = txhash
-	if nftEvent.Update(ctx.GormDb) != nil {
-		return
-	}
-	//change nft state
-	nft.NftStatus = model.MINT.String()
-	nft.Owner = to
-	nft.Price = model.NULL
-	//unexpected error,rollback
-	if nft.Update(ctx.GormDb) != nil {
-		nftEvent.State = model.FAILED.String()
-		nftEvent.Update(ctx.GormDb)
-	}
-	logger.Info("[Nft transfer service] exit async listening")
+	if err := checkEvent(&nft.VideoMetadata, model.NFT_MELT, model.NftInput{Actor: owner}); err != nil {
+		return res, err
+	}
+	if err := checkNotReserved(&nft.VideoMetadata); err != nil {
+		return res, err
+	}
+	//create activity
+	nftEvent := &model.Activity{
+		EventType: model.ACT_MELT.String(),
+		Creator:   nft.Owner,
+		Source:    nft.Owner,
+		Target:    model.NULL,
+		FileHash:  filehash,
+		State:     model.LISTENING.String(),
+		NftToken:  nft.NftToken,
+		StartDate: time.Now().Local().Format(ctx.Time_FMT),
+	}
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
+		return res, errors.Wrap(err, "create melt activity error")
+	}
+	//listen events
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func ChangeStatus(filehash, status, price string, data TxData) (dto.EventResp, error) {
+// ChangeStatus lists the nft at the price in the currency, empty for the chain token, or unlists it
+func ChangeStatus(filehash, token, actor, status, price, currency string, data TxData) (dto.EventResp, error) {
 	//query metadata
 	var res dto.EventResp
-	nft := &model.VideoMetadata{FileHash: filehash}
-	resp, err := nft.Get(ctx.GormDb)
-	if err != nil || len(resp) != 1 {
-		return res, errors.New("query nft metadata error")
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return res, err
 	}
-	nft = &resp[0]
 	//check
-	status = strings.ToLower(status)
-	if status != "list" && status != "unlist" {
-		return res, errors.New("status error")
-	}
-	if status == "list" {
+	in := model.NftInput{Actor: actor}
+	evt := model.NftEvent(strings.ToLower(status))
+	switch evt {
+	case model.NFT_LIST:
 		status = model.LIST.String()
-		if p, err := strconv.ParseFloat(price, 64); err != nil || p < 0 {
-			return res, errors.Wrap(err, "invalid price")
+		money, c, err := parseListingPrice(price, currency)
+		if err != nil {
+			return res, err
 		}
-	} else {
+		in.Price, in.Currency = money, c.Code()
+	case model.NFT_UNLIST:
 		status = model.MINT.String()
+	default:
+		return res, errors.New("status error")
 	}
-	if nft.NftStatus == status {
-		return res, errors.New("status error,nft already list or unlist")
+	if err := checkEvent(&nft.VideoMetadata, evt, in); err != nil {
+		return res, err
+	}
+	if err := checkNotReserved(&nft.VideoMetadata); err != nil {
+		return res, err
 	}
 	//create activity
 	nftEvent := &model.Activity{
@@ -388,139 +401,77 @@ func ChangeStatus(filehash, status, price string, data TxData) (dto.EventResp, e
 		FileHash:  filehash,
 		State:     model.LISTENING.String(),
 		NftToken:  nft.NftToken,
-		Price:     price,
+		Price:     in.Price,
+		Currency:  in.Currency,
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = nftEvent.Create(ctx.GormDb)
-	if err != nil {
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
 		return res, errors.Wrap(err, "create change status activity error")
 	}
-
-	nftEvent.EndDate = time.Now().Local().Format(ctx.Time_FMT)
-	res.EventType = nftEvent.EventType
-	res.From = nftEvent.Source
-	res.To = nftEvent.Target
-	res.Price = price
 	//listen events
-	//tx failed
-	// ants.Submit(func() {
-	// 	cc(filehash, status, price, data, nftEvent, nft)
-	// })
-	cc(status, price, data, nftEvent, nft)
-	res.State = nftEvent.State
-	res.Date = nftEvent.EndDate
-	return res, nil
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func cc(status, price string, data TxData, nftEvent *model.Activity, nft *model.VideoMetadata) {
-	logger.Info("[Nft change status service] entry async listening...")
-	var (
-		txhash string
-		err    error
-	)
-	if data.IsSent {
-		txhash = data.Data
-	} else {
-		txhash, err = ctx.ChainClient.SendTx1(data.Data)
-		if err != nil {
-			nftEvent.State = model.FAILED.String()
-			nftEvent.Update(ctx.GormDb)
-			return
-		}
-	}
-	//tx success,update activity state
-	nftEvent.State = model.SUCCESS.String()
-	nftEvent.TxHash = txhash
-	if nftEvent.Update(ctx.GormDb) != nil {
-		return
-	}
-	//change nft state
-	nft.NftStatus = status
-	nft.Price = price
-	//unexpected error,rollback
-	if nft.Update(ctx.GormDb) != nil {
-		nftEvent.State = model.FAILED.String()
-		nftEvent.Update(ctx.GormDb)
-	}
-	logger.Info("[Nft change status service] exit async listening")
-}
-
-func ChangePrice(filehash, price string, data TxData) (dto.EventResp, error) {
+// ChangePrice changes the price of the listed nft, the currency may be changed along
+func ChangePrice(filehash, token, actor, price, currency string, data TxData) (dto.EventResp, error) {
 	//query metadata
 	var res dto.EventResp
-	nft := &model.VideoMetadata{FileHash: filehash}
-	resp, err := nft.Get(ctx.GormDb)
-	if err != nil || len(resp) != 1 {
-		return res, errors.New("query nft metadata error")
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return res, err
 	}
-	nft = &resp[0]
 	//check
-	if price == nft.Price {
-		return res, errors.New("price is the same as before")
+	money, c, err := parseListingPrice(price, currency)
+	if err != nil {
+		return res, err
+	}
+	in := model.NftInput{Actor: actor, Price: money, Currency: c.Code()}
+	if err := checkEvent(&nft.VideoMetadata, model.NFT_REPRICE, in); err != nil {
+		return res, err
 	}
-	if nft.NftStatus != model.LIST.String() {
-		return res, errors.New("status error,nft not list")
+	if err := checkNotReserved(&nft.VideoMetadata); err != nil {
+		return res, err
+	}
+	if money.Equal(nft.Price) && in.Currency == nft.Currency {
+		return res, errors.New("price is the same as before")
 	}
 	//create activity
 	nftEvent := &model.Activity{
 		EventType: model.ACT_ALT.String(),
 		Creator:   nft.Creator,
-		Source:    nft.Price,
-		Target:    price,
+		Source:    listingPrice(nft.Price, nft.Currency),
+		Target:    listingPrice(money, in.Currency),
 		FileHash:  filehash,
 		State:     model.LISTENING.String(),
 		NftToken:  nft.NftToken,
-		Price:     price,
+		Price:     money,
+		Currency:  in.Currency,
 		StartDate: time.Now().Local().Format(ctx.Time_FMT),
 	}
-	err = nftEvent.Create(ctx.GormDb)
-	if err != nil {
+	if err = bindTx(nftEvent, data); err != nil {
+		return res, err
+	}
+	if err = recordActivity(nft, nftEvent); err != nil {
 		return res, errors.Wrap(err, "create change price activity error")
 	}
-
-	nftEvent.EndDate = time.Now().Local().Format(ctx.Time_FMT)
-	res.EventType = nftEvent.EventType
-	res.From = nftEvent.Source
-	res.To = nftEvent.Target
-	res.Price = price
 	//listen events
-	// ants.Submit(func() {
-	// 	dd(price, data, nftEvent, nft)
-	// })
-	dd(price, data, nftEvent, nft)
-	res.State = nftEvent.State
-	res.Date = nftEvent.EndDate
-	return res, nil
+	txTracker.Track(*nftEvent)
+	return toEventResp(nftEvent), nil
 }
 
-func dd(price string, data TxData, nftEvent *model.Activity, nft *model.VideoMetadata) {
-	logger.Info("[Nft change price service] entry async listening...")
-	var (
-		txhash string
-		err    error
-	)
-	if data.IsSent {
-		txhash = data.Data
-	} else {
-		txhash, err = ctx.ChainClient.SendTx1(data.Data)
-		if err != nil {
-			nftEvent.State = model.FAILED.String()
-			nftEvent.Update(ctx.GormDb)
-			return
-		}
-	}
-	//tx success,update activity state
-	nftEvent.State = model.SUCCESS.String()
-	nftEvent.TxHash = txhash
-	if nftEvent.Update(ctx.GormDb) != nil {
-		return
-	}
-	//change nft state
-	nft.Price = price
-	//unexpected error,rollback
-	if nft.Update(ctx.GormDb) != nil {
-		nftEvent.State = model.FAILED.String()
-		nftEvent.Update(ctx.GormDb)
+// EstimateFee estimates the fee of the extrinsic for the frontend to show before it's sent
+func EstimateFee(extHex string) (*dto.FeeResp, error) {
+	info, err := ctx.ChainClient.EstimateFee(extHex)
+	if err != nil {
+		return nil, err
 	}
-	logger.Info("[Nft change price service] exit async listening")
+	return &dto.FeeResp{
+		Fee:    formatFee(info.Fee),
+		Planck: info.Fee.String(),
+		Class:  info.Class,
+	}, nil
 }
@@ -0,0 +1,389 @@
+package nft
+
+import (
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	OFFER_MIN_DURATION = 10 * time.Minute
+	OFFER_MAX_DURATION = 30 * 24 * time.Hour
+)
+
+const (
+	OFFER_ACCEPT   = "accept"
+	OFFER_REJECT   = "reject"
+	OFFER_COUNTER  = "counter"
+	OFFER_WITHDRAW = "withdraw"
+	// the owner lists the nft at the agreed price of the accepted offer
+	OFFER_LIST = "list"
+)
+
+var ERR_OFFER_CLOSED = errors.New("the offer is closed")
+
+func mintedItem(filehash, token string) (*item, error) {
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return nil, err
+	}
+	if nft.NftStatus != model.MINT.String() && nft.NftStatus != model.LIST.String() {
+		return nil, errors.New("nft is not minted or on auction")
+	}
+	return nft, nil
+}
+
+// MakeOffer offers to buy the minted nft whether it's listed or not, the offer
+// is withdrawn automatically if it's not accepted before it expires.
+// The offer may be in another currency than the listed price, the owner relists the nft in it once accepted.
+func MakeOffer(buyer string, req dto.MakeOfferReq) (*dto.OfferResp, error) {
+	nft, err := mintedItem(req.FileHash, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if buyer == nft.Owner {
+		return nil, errors.New("unable to make an offer on your own nft")
+	}
+	price, currency, err := parseListingPrice(req.Price, req.Currency)
+	if err != nil || price.Planck().Sign() == 0 {
+		return nil, errors.New("invalid offer price")
+	}
+	expireAt := time.Unix(req.ExpireAt, 0)
+	if d := time.Until(expireAt); d < OFFER_MIN_DURATION || d > OFFER_MAX_DURATION {
+		return nil, errors.Errorf("the offer must last between %s and %s", OFFER_MIN_DURATION, OFFER_MAX_DURATION)
+	}
+	if err := checkAffordable(buyer, currency, price.Planck()); err != nil {
+		return nil, err
+	}
+	offer := &model.Offer{
+		FileHash: nft.FileHash,
+		NftToken: nft.NftToken,
+		Buyer:    buyer,
+		Owner:    nft.Owner,
+		Price:    price,
+		Currency: currency.Code(),
+		State:    model.OFFER_OPEN.String(),
+		ExpireAt: expireAt,
+	}
+	err = ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		act := &model.Activity{
+			EventType: model.ACT_OFFER.String(),
+			Creator:   buyer,
+			Source:    buyer,
+			Target:    nft.Owner,
+			FileHash:  nft.FileHash,
+			NftToken:  nft.NftToken,
+			Price:     price,
+			Currency:  offer.Currency,
+			State:     model.PENDING.String(),
+			StartDate: time.Now().Local().Format(ctx.Time_FMT),
+		}
+		if err := act.Create(tx); err != nil {
+			return err
+		}
+		offer.ActivityId = act.Id
+		return offer.Create(tx)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "make offer error")
+	}
+	return offerResp(offer), nil
+}
+
+// RespondOffer lets the owner accept, reject or counter the open offer, the buyer
+// accept or reject the counter offer, and the buyer withdraw the offer.
+// An accepted offer reserves the nft for the buyer to buy at the agreed price,
+// the owner lists it at the price on chain with the tx along the accept or the list action.
+func RespondOffer(offerId int64, caller string, req dto.RespondOfferReq) (*dto.OfferResp, error) {
+	marketLock.Lock()
+	defer marketLock.Unlock()
+	offer := &model.Offer{Id: offerId}
+	if err := offer.Take(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "query offer error")
+	}
+	pending := offer.State == model.OFFER_OPEN.String() || offer.State == model.OFFER_COUNTERED.String()
+	if req.Action == OFFER_LIST {
+		pending = offer.State == model.OFFER_ACCEPTED.String()
+	}
+	if !pending || !time.Now().Before(offer.ExpireAt) {
+		return nil, ERR_OFFER_CLOSED
+	}
+	// the party the offer is waiting for
+	responder := offer.Owner
+	if offer.State == model.OFFER_COUNTERED.String() {
+		responder = offer.Buyer
+	}
+	var err error
+	switch req.Action {
+	case OFFER_WITHDRAW:
+		if caller != offer.Buyer {
+			return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+		}
+		err = closeOffer(ctx.GormDb, offer, model.OFFER_WITHDRAWN, model.WITHDRAW)
+	case OFFER_REJECT:
+		if caller != responder {
+			return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+		}
+		err = closeOffer(ctx.GormDb, offer, model.OFFER_REJECTED, model.FAILED)
+	case OFFER_COUNTER:
+		if caller != offer.Owner {
+			return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+		}
+		// countered in the currency of the offer
+		counter, _, perr := parseListingPrice(req.Price, offer.Currency)
+		if perr != nil || counter.Planck().Sign() == 0 {
+			return nil, errors.New("invalid counter price")
+		}
+		offer.CounterPrice = counter
+		offer.State = model.OFFER_COUNTERED.String()
+		err = offer.Update(ctx.GormDb)
+	case OFFER_ACCEPT:
+		if caller != responder {
+			return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+		}
+		err = acceptOffer(offer, caller, req)
+	case OFFER_LIST:
+		if caller != offer.Owner {
+			return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+		}
+		err = listAcceptedOffer(offer, req)
+	default:
+		return nil, errors.Errorf("unknown offer action: %s", req.Action)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "respond offer error")
+	}
+	return offerResp(offer), nil
+}
+
+// acceptOffer reserves the nft for the buyer at the agreed price. The owner accepting the offer
+// lists the nft at it with the tx along, the owner lists it with the list action once the buyer
+// accepts the counter offer. The buyer settles it like any purchase once the listing is finalized.
+func acceptOffer(offer *model.Offer, caller string, req dto.RespondOfferReq) error {
+	nft, err := mintedItem(offer.FileHash, offer.NftToken)
+	if err != nil {
+		return err
+	}
+	if nft.Owner != offer.Owner {
+		return errors.New("the nft has changed hands")
+	}
+	if err := checkNotReserved(&nft.VideoMetadata); err != nil {
+		return err
+	}
+	price := offer.Price
+	if offer.State == model.OFFER_COUNTERED.String() {
+		price = offer.CounterPrice
+	}
+	currency, err := model.LookupCurrency(offer.Currency)
+	if err != nil {
+		return err
+	}
+	if err := checkAffordable(offer.Buyer, currency, price.Planck()); err != nil {
+		return err
+	}
+	offer.Price = price
+	offer.State = model.OFFER_ACCEPTED.String()
+	offer.ExpireAt = time.Now().Add(RESERVATION_TIMEOUT)
+	if caller != offer.Owner || listedAt(nft, offer) {
+		return offer.Update(ctx.GormDb)
+	}
+	return listOffer(nft, offer, req)
+}
+
+// listedAt tells if the nft is listed at the agreed price of the offer already
+func listedAt(nft *item, offer *model.Offer) bool {
+	return nft.NftStatus == model.LIST.String() && nft.Price.Equal(offer.Price) && nft.Currency == offer.Currency
+}
+
+// listAcceptedOffer lists the nft of the accepted offer at the agreed price for the buyer to buy
+func listAcceptedOffer(offer *model.Offer, req dto.RespondOfferReq) error {
+	nft, err := mintedItem(offer.FileHash, offer.NftToken)
+	if err != nil {
+		return err
+	}
+	if nft.Owner != offer.Owner {
+		return errors.New("the nft has changed hands")
+	}
+	if listedAt(nft, offer) {
+		return errors.New("the nft is listed at the agreed price already")
+	}
+	return listOffer(nft, offer, req)
+}
+
+// listOffer lists the nft on chain at the agreed price of the offer with the tx of the owner,
+// the listing is tracked like any other and the nft is listed once it's finalized
+func listOffer(nft *item, offer *model.Offer, req dto.RespondOfferReq) error {
+	// the listing of the offer or another operation on the nft is yet to be finalized
+	inFlight, err := txInFlight(nft.NftToken)
+	if err != nil {
+		return err
+	}
+	if inFlight {
+		return errors.New("the nft has a tx being tracked")
+	}
+	data := TxData{IsSent: true, Data: req.TxHash, Signer: offer.Owner}
+	if req.Signtx != "" {
+		data = TxData{IsSent: false, Data: req.Signtx, Signer: offer.Owner}
+	} else if req.TxHash == "" {
+		return errors.New("the tx listing the nft at the agreed price is required")
+	}
+	act := &model.Activity{
+		EventType: model.ACT_ALT.String(),
+		Creator:   nft.Creator,
+		Source:    nft.NftStatus,
+		Target:    model.LIST.String(),
+		FileHash:  nft.FileHash,
+		State:     model.LISTENING.String(),
+		NftToken:  nft.NftToken,
+		Price:     offer.Price,
+		Currency:  offer.Currency,
+		StartDate: time.Now().Local().Format(ctx.Time_FMT),
+	}
+	if nft.NftStatus == model.LIST.String() {
+		act.Source, act.Target = listingPrice(nft.Price, nft.Currency), listingPrice(offer.Price, offer.Currency)
+	}
+	if err := bindTx(act, data); err != nil {
+		return err
+	}
+	err = ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if err := act.Create(tx); err != nil {
+			return err
+		}
+		offer.ListingId = act.Id
+		if err := offer.Update(tx); err != nil {
+			return err
+		}
+		return nft.Update(tx)
+	})
+	if err != nil {
+		return errors.Wrap(err, "create offer listing activity error")
+	}
+	txTracker.Track(*act)
+	return nil
+}
+
+// BuildOfferListing builds the unsigned tx listing the nft at the agreed price of the offer for the owner
+// to sign, the open offer is agreed at its price once the owner accepts it with the tx
+func BuildOfferListing(offerId int64, caller string) (*dto.OfferListingResp, error) {
+	offer := &model.Offer{Id: offerId}
+	if err := offer.Take(ctx.GormDb); err != nil {
+		return nil, errors.Wrap(err, "query offer error")
+	}
+	if caller != offer.Owner {
+		return nil, &ForbiddenError{Actor: caller, Op: OP_OFFER}
+	}
+	open := offer.State == model.OFFER_OPEN.String() || offer.State == model.OFFER_ACCEPTED.String()
+	if !open || !time.Now().Before(offer.ExpireAt) {
+		return nil, ERR_OFFER_CLOSED
+	}
+	nft, err := mintedItem(offer.FileHash, offer.NftToken)
+	if err != nil {
+		return nil, err
+	}
+	callName := chain.CALL_NFT_LIST
+	if nft.NftStatus == model.LIST.String() {
+		callName = chain.CALL_NFT_UPDATE_PRICE
+	}
+	args, err := priceArgs(offer.Price, offer.Currency)
+	if err != nil {
+		return nil, err
+	}
+	call, err := ctx.ChainClient.NewCall(callName, append([]any{tokenArg(nft.NftToken)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := codec.EncodeToHex(types.NewExtrinsic(call))
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.OfferListingResp{Extrinsic: ext}
+	res.Price, res.Currency = model.FormatPrice(offer.Price, offer.Currency)
+	return res, nil
+}
+
+// closeOffer closes the offer along with the activity it's recorded with
+func closeOffer(tx *gorm.DB, offer *model.Offer, state model.OfferState, actState model.ActivityState) error {
+	offer.State = state.String()
+	if err := offer.Update(tx); err != nil {
+		return err
+	}
+	return tx.Model(&model.Activity{}).Where("id = ?", offer.ActivityId).Updates(map[string]any{
+		"state":    actState.String(),
+		"end_date": time.Now().Local().Format(ctx.Time_FMT),
+	}).Error
+}
+
+// expireOffers withdraws the offers not accepted in time, and the accepted offers whose buyer
+// didn't buy the nft in time unless the purchase is still tracked, the nft is unlisted then.
+func expireOffers() {
+	marketLock.Lock()
+	defer marketLock.Unlock()
+	expired, err := model.QueryOffers(ctx.GormDb, "state IN ? AND expire_at <= ?",
+		[]string{model.OFFER_OPEN.String(), model.OFFER_COUNTERED.String(), model.OFFER_ACCEPTED.String()}, time.Now())
+	if err != nil {
+		logger.Error(err, "[Offer] query expired offers error")
+		return
+	}
+	for i := range expired {
+		offer := &expired[i]
+		if offer.State == model.OFFER_ACCEPTED.String() {
+			inFlight, err := txInFlight(offer.NftToken)
+			if err != nil {
+				logger.Error(err, "[Offer] check the tracked purchase error", "offerId", offer.Id)
+			}
+			if err != nil || inFlight {
+				continue
+			}
+		}
+		err := ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+			if offer.State == model.OFFER_ACCEPTED.String() {
+				nft, err := loadItem(tx, offer.FileHash, offer.NftToken)
+				if err != nil {
+					return err
+				}
+				// the owner didn't list the nft for the offer
+				if nft.NftStatus != model.LIST.String() {
+					return closeOffer(tx, offer, model.OFFER_WITHDRAWN, model.WITHDRAW)
+				}
+				if err := marketStatusActivity(tx, &nft.VideoMetadata, model.MINT, model.Money{}, ""); err != nil {
+					return err
+				}
+				if err := nft.fire(model.NFT_RELEASE, model.NftInput{}); err != nil {
+					return err
+				}
+				if err := nft.Update(tx); err != nil {
+					return err
+				}
+			}
+			return closeOffer(tx, offer, model.OFFER_WITHDRAWN, model.WITHDRAW)
+		})
+		if err != nil {
+			logger.Error(err, "[Offer] withdraw expired offer error", "offerId", offer.Id)
+		}
+	}
+}
+
+// QueryOffers returns the offers made by the wallet or to it, the latest first
+func QueryOffers(walletAddress, filehash string) ([]*dto.OfferResp, error) {
+	query := ctx.GormDb.Where("buyer = ? OR owner = ?", walletAddress, walletAddress)
+	if filehash != "" {
+		query = query.Where("file_hash = ?", filehash)
+	}
+	var offers []model.Offer
+	if err := query.Order("id DESC").Find(&offers).Error; err != nil {
+		return nil, errors.Wrap(err, "query offers error")
+	}
+	res := make([]*dto.OfferResp, 0, len(offers))
+	for i := range offers {
+		res = append(res, offerResp(&offers[i]))
+	}
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package nft
+
+import (
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/oracle"
+
+	"github.com/pkg/errors"
+)
+
+// fiatValue is the approximate value of the price in the fiat currency of the price oracle,
+// nil if there's no oracle, no price or no rate of the currency
+func fiatValue(m model.Money, currency string) *dto.FiatValue {
+	if ctx.PriceOracle == nil || m.IsNull() {
+		return nil
+	}
+	c, err := model.LookupCurrency(currency)
+	if err != nil {
+		return nil
+	}
+	v, err := oracle.FiatValue(ctx.PriceOracle, c.Symbol, m.Planck(), c.Decimals)
+	if err != nil {
+		return nil
+	}
+	return &dto.FiatValue{Value: v.FloatString(2), Currency: ctx.PriceOracle.Fiat()}
+}
+
+// videoResp is the video with its price in units of its currency and the fiat value
+func videoResp(v model.VideoMetadata) dto.VideoResp {
+	r := dto.VideoResp{VideoMetadata: v, Fiat: fiatValue(v.Price, v.Currency)}
+	r.Price, r.Currency = model.FormatPrice(v.Price, v.Currency)
+	return r
+}
+
+func auctionResp(a *model.Auction, bids []model.Bid) *dto.AuctionResp {
+	r := &dto.AuctionResp{Auction: a}
+	r.ReservePrice, r.Currency = model.FormatPrice(a.ReservePrice, a.Currency)
+	if !a.HighestBid.IsNull() {
+		r.HighestBid, _ = model.FormatPrice(a.HighestBid, a.Currency)
+	}
+	for _, b := range bids {
+		br := dto.BidResp{Bid: b}
+		br.Amount, _ = model.FormatPrice(b.Amount, a.Currency)
+		r.BidList = append(r.BidList, br)
+	}
+	return r
+}
+
+func offerResp(o *model.Offer) *dto.OfferResp {
+	r := &dto.OfferResp{Offer: o}
+	r.Price, r.Currency = model.FormatPrice(o.Price, o.Currency)
+	if !o.CounterPrice.IsNull() {
+		r.CounterPrice, _ = model.FormatPrice(o.CounterPrice, o.Currency)
+	}
+	return r
+}
+
+// parseListingPrice parses the price the seller asks in units of the currency, the chain token for empty
+func parseListingPrice(price, currency string) (model.Money, model.Currency, error) {
+	c, err := model.LookupCurrency(currency)
+	if err != nil {
+		return model.Money{}, c, err
+	}
+	m, err := c.Parse(price)
+	if err != nil || m.IsNull() {
+		return m, c, errors.Errorf("invalid price: %s", price)
+	}
+	return m, c, nil
+}
+
+// listingPrice is the price along with the symbol of its currency, such as "1.5 USDT"
+func listingPrice(m model.Money, currency string) string {
+	price, symbol := model.FormatPrice(m, currency)
+	if m.IsNull() {
+		return price
+	}
+	return price + " " + symbol
+}
@@ -0,0 +1,78 @@
+package nft
+
+import (
+	"testing"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/oracle"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiatValue(t *testing.T) {
+	require := require.New(t)
+	model.AddAsset("USDT", 1984, 6)
+	defer delete(model.Assets, "USDT")
+	defer func() { ctx.PriceOracle = nil }()
+
+	v := model.VideoMetadata{FileHash: "file-hash", Price: mustMoney(t, "1.5")}
+	require.Nil(videoResp(v).Fiat)
+
+	o, err := oracle.ParseStaticRates([]byte(`{"fiat": "USD", "rates": {"CESS": "0.5", "USDT": "1"}}`))
+	require.NoError(err)
+	ctx.PriceOracle = o
+	r := videoResp(v)
+	require.Equal("1.5", r.Price)
+	require.Equal(model.TokenSymbol, r.Currency)
+	require.Equal(&dto.FiatValue{Value: "0.75", Currency: "USD"}, r.Fiat)
+
+	v.Price, _, err = parseListingPrice("12.345", "usdt")
+	require.NoError(err)
+	v.Currency = "USDT"
+	r = videoResp(v)
+	require.Equal("12.345", r.Price)
+	require.Equal("USDT", r.Currency)
+	require.Equal("12.35", r.Fiat.Value)
+	require.Equal("12.345 USDT", listingPrice(v.Price, v.Currency))
+
+	// no price, no rate
+	require.Nil(fiatValue(model.Money{}, ""))
+	model.AddAsset("EUROC", 7, 6)
+	defer delete(model.Assets, "EUROC")
+	require.Nil(fiatValue(v.Price, "EUROC"))
+
+	_, _, err = parseListingPrice("1", "DOT")
+	require.ErrorIs(err, model.ERR_UNKNOWN_CURRENCY)
+	_, _, err = parseListingPrice("--", "USDT")
+	require.Error(err)
+}
+
+func TestPriceInPlanckFilter(t *testing.T) {
+	require := require.New(t)
+	model.AddAsset("USDT", 1984, 6)
+	defer delete(model.Assets, "USDT")
+
+	q := dto.Querier{Filter: []dto.FilterItem{{Column: "price", Sign: "between", Values: []any{"1", 2.5}}}}
+	res, err := priceInPlanckFilter(q)
+	require.NoError(err)
+	require.Equal([]any{"1000000000000", "2500000000000"}, res.Filter[0].Values)
+	// the querier of the caller is left alone
+	require.Equal([]any{"1", 2.5}, q.Filter[0].Values)
+
+	q.Filter = append(q.Filter, dto.FilterItem{Column: "currency", Sign: "=", Values: []any{"usdt"}})
+	res, err = priceInPlanckFilter(q)
+	require.NoError(err)
+	require.Equal([]any{"1000000", "2500000"}, res.Filter[0].Values)
+	require.Equal([]any{"USDT"}, res.Filter[1].Values)
+
+	q.Filter[1].Values = []any{model.TokenSymbol}
+	res, err = priceInPlanckFilter(q)
+	require.NoError(err)
+	require.Equal([]any{""}, res.Filter[1].Values)
+
+	q.Filter[0].Values = []any{"abc"}
+	_, err = priceInPlanckFilter(q)
+	require.Error(err)
+}
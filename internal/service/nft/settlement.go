@@ -0,0 +1,313 @@
+package nft
+
+import (
+	"math/big"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/dto"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/paging"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// the royalty can't be more than half of the price
+const MAX_ROYALTY = 50
+
+var ERR_UNDERPAID = errors.New("the buyer didn't pay every share of the listed price")
+
+// ERR_EVENTS_UNAVAILABLE is the failed query of the block events, it may succeed if retried
+var ERR_EVENTS_UNAVAILABLE = errors.New("the events of the block are unavailable")
+
+// purchaseSplit is how the price paid by the buyer is split,
+// there's no royalty for the sale made by the creator.
+type purchaseSplit struct {
+	Seller   string
+	Creator  string
+	Platform string
+	// every share is paid in the currency of the price
+	Currency    model.Currency
+	Price       *big.Int
+	SellerShare *big.Int
+	Royalty     *big.Int
+	PlatformFee *big.Int
+}
+
+type payment struct {
+	To     string
+	Amount *big.Int
+}
+
+func percentOf(amount *big.Int, percent uint32) *big.Int {
+	r := new(big.Int).Mul(amount, big.NewInt(int64(percent)))
+	return r.Quo(r, big.NewInt(100))
+}
+
+func splitPurchase(price *big.Int, currency model.Currency, seller string, nft *model.VideoMetadata) (*purchaseSplit, error) {
+	split := &purchaseSplit{
+		Seller:   seller,
+		Creator:  nft.Creator,
+		Platform: ctx.Settings.Web3Setting.SupperAddress,
+		Currency: currency,
+		Price:    price,
+	}
+	royalty, feeRate := nft.Royalty, ctx.Settings.Web3Setting.PlatformFeeRate
+	if seller == nft.Creator {
+		royalty = 0
+	}
+	if split.Platform == "" {
+		feeRate = 0
+	}
+	if royalty+feeRate > 100 {
+		return nil, errors.Errorf("royalty %d%% and platform fee %d%% exceed the price", royalty, feeRate)
+	}
+	split.Royalty = percentOf(price, royalty)
+	split.PlatformFee = percentOf(price, feeRate)
+	split.SellerShare = new(big.Int).Sub(price, split.Royalty)
+	split.SellerShare.Sub(split.SellerShare, split.PlatformFee)
+	return split, nil
+}
+
+// payments merges the shares by recipient, the zero shares are left out
+func (t *purchaseSplit) payments() []payment {
+	var res []payment
+	add := func(to string, amount *big.Int) {
+		if amount.Sign() == 0 {
+			return
+		}
+		for i := range res {
+			if res[i].To == to {
+				res[i].Amount = new(big.Int).Add(res[i].Amount, amount)
+				return
+			}
+		}
+		res = append(res, payment{To: to, Amount: amount})
+	}
+	add(t.Seller, t.SellerShare)
+	add(t.Creator, t.Royalty)
+	add(t.Platform, t.PlatformFee)
+	return res
+}
+
+// listedItem returns the nft if it's listed for sale
+func listedItem(filehash, token string) (*item, error) {
+	nft, err := loadItem(ctx.GormDb, filehash, token)
+	if err != nil {
+		return nil, err
+	}
+	if nft.NftStatus != model.LIST.String() || nft.Price.IsNull() {
+		return nil, errors.New("nft not list or price error")
+	}
+	return nft, nil
+}
+
+// transferCall pays the share in the currency of the price
+func (t *purchaseSplit) transferCall(p payment) (types.Call, error) {
+	to, err := accountIdArg(p.To)
+	if err != nil {
+		return types.Call{}, err
+	}
+	dest, err := types.NewMultiAddressFromAccountID(to[:])
+	if err != nil {
+		return types.Call{}, err
+	}
+	if t.Currency.IsNative() {
+		return ctx.ChainClient.NewCall(chain.CALL_BALANCES_TRANSFER, dest, types.NewUCompact(p.Amount))
+	}
+	assetId := types.NewUCompactFromUInt(uint64(t.Currency.AssetId))
+	return ctx.ChainClient.NewCall(chain.CALL_ASSETS_TRANSFER, assetId, dest, types.NewUCompact(p.Amount))
+}
+
+// transferred sums up what the extrinsic of the index paid between the accounts in the currency of the price
+func (t *purchaseSplit) transferred(evts []*parser.Event, index uint32, from, to types.AccountID) (*big.Int, error) {
+	if t.Currency.IsNative() {
+		return chain.TransferredAmount(evts, index, from, to)
+	}
+	return chain.TransferredAssetAmount(evts, index, t.Currency.AssetId, from, to)
+}
+
+// listedSplit splits the listed price of the nft with the current royalty and platform fee
+func listedSplit(nft *model.VideoMetadata) (*purchaseSplit, error) {
+	currency, err := model.LookupCurrency(nft.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return splitPurchase(nft.Price.Planck(), currency, nft.Owner, nft)
+}
+
+// keep keeps the shares with the purchase activity, the payment is verified against them
+// even if the royalty or the platform fee is changed before the tx is finalized.
+func (t *purchaseSplit) keep(act *model.Activity) {
+	act.Royalty = model.NewMoney(t.Royalty)
+	act.PlatformFee = model.NewMoney(t.PlatformFee)
+	act.Platform = t.Platform
+}
+
+// purchaseCall buys the nft and pays every share of the listed price in a single Utility.batch_all
+func purchaseCall(nft *model.VideoMetadata) (types.Call, *purchaseSplit, error) {
+	split, err := listedSplit(nft)
+	if err != nil {
+		return types.Call{}, nil, err
+	}
+	calls := make([]types.Call, 0, 4)
+	buy, err := ctx.ChainClient.NewCall(chain.CALL_NFT_BUY, tokenArg(nft.NftToken))
+	if err != nil {
+		return types.Call{}, nil, err
+	}
+	calls = append(calls, buy)
+	for _, p := range split.payments() {
+		pay, err := split.transferCall(p)
+		if err != nil {
+			return types.Call{}, nil, err
+		}
+		calls = append(calls, pay)
+	}
+	call, err := ctx.ChainClient.NewCall(chain.CALL_UTILITY_BATCH_ALL, calls)
+	return call, split, err
+}
+
+// BuildPurchase builds the unsigned purchase extrinsic for the buyer to sign
+func BuildPurchase(filehash, token string) (*dto.PurchaseBuildResp, error) {
+	nft, err := listedItem(filehash, token)
+	if err != nil {
+		return nil, err
+	}
+	call, split, err := purchaseCall(&nft.VideoMetadata)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := codec.EncodeToHex(types.NewExtrinsic(call))
+	if err != nil {
+		return nil, err
+	}
+	return &dto.PurchaseBuildResp{
+		Extrinsic:   ext,
+		Seller:      nft.Owner,
+		Price:       split.Currency.Format(nft.Price),
+		Currency:    split.Currency.Symbol,
+		Royalty:     split.Currency.Format(model.NewMoney(split.Royalty)),
+		PlatformFee: split.Currency.Format(model.NewMoney(split.PlatformFee)),
+	}, nil
+}
+
+// checkPurchasePaid checks the transfers made by the purchase extrinsic,
+// every recipient must have been paid its share by the buyer.
+func checkPurchasePaid(split *purchaseSplit, buyer string, evts []*parser.Event, index uint32) error {
+	from, err := accountIdArg(buyer)
+	if err != nil {
+		return err
+	}
+	for _, p := range split.payments() {
+		to, err := accountIdArg(p.To)
+		if err != nil {
+			return err
+		}
+		paid, err := split.transferred(evts, index, *from, *to)
+		if err != nil {
+			return err
+		}
+		if paid.Cmp(p.Amount) < 0 {
+			return errors.Wrapf(ERR_UNDERPAID, "paid %s of %s to %s", paid, p.Amount, p.To)
+		}
+	}
+	return nil
+}
+
+// activitySplit is the split kept with the purchase activity, it must be called before the activity is applied
+func activitySplit(act *model.Activity) (*purchaseSplit, error) {
+	nft, err := loadItem(ctx.GormDb, act.FileHash, act.NftToken)
+	if err != nil {
+		return nil, err
+	}
+	if act.Price.IsNull() {
+		return nil, errors.Errorf("purchase activity %d has no price", act.Id)
+	}
+	currency, err := model.LookupCurrency(act.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if act.Royalty.IsNull() || act.PlatformFee.IsNull() {
+		return nil, errors.Errorf("purchase activity %d has no split kept", act.Id)
+	}
+	return keptSplit(act, currency, nft.Creator)
+}
+
+// keptSplit rebuilds the split from the shares kept with the purchase activity
+func keptSplit(act *model.Activity, currency model.Currency, creator string) (*purchaseSplit, error) {
+	split := &purchaseSplit{
+		Seller:      act.Source,
+		Creator:     creator,
+		Platform:    act.Platform,
+		Currency:    currency,
+		Price:       act.Price.Planck(),
+		Royalty:     act.Royalty.Planck(),
+		PlatformFee: act.PlatformFee.Planck(),
+	}
+	split.SellerShare = new(big.Int).Sub(split.Price, split.Royalty)
+	split.SellerShare.Sub(split.SellerShare, split.PlatformFee)
+	if split.SellerShare.Sign() < 0 {
+		return nil, errors.Errorf("the shares of purchase activity %d exceed the price", act.Id)
+	}
+	return split, nil
+}
+
+// verifyPurchasePayment checks the purchase activity has been paid before the nft changes hands,
+// only an error of ERR_EVENTS_UNAVAILABLE is worth retrying.
+func verifyPurchasePayment(act *model.Activity, ie *chain.IncludedExtrinsic) (*purchaseSplit, error) {
+	evts, err := ctx.ChainClient.BlockEvents(ie.BlockHash)
+	if err != nil {
+		return nil, errors.Wrap(ERR_EVENTS_UNAVAILABLE, err.Error())
+	}
+	split, err := activitySplit(act)
+	if err != nil {
+		return nil, err
+	}
+	return split, checkPurchasePaid(split, act.Target, evts, ie.Index)
+}
+
+// recordRoyalty records the royalty paid to the creator by the finalized purchase
+func recordRoyalty(tx *gorm.DB, act *model.Activity, split *purchaseSplit) error {
+	if split.Royalty.Sign() == 0 {
+		return nil
+	}
+	royalty := &model.Activity{
+		EventType:   model.ACT_ROYALTY.String(),
+		Creator:     split.Creator,
+		Source:      act.Target,
+		Target:      split.Creator,
+		FileHash:    act.FileHash,
+		NftToken:    act.NftToken,
+		Price:       model.NewMoney(split.Royalty),
+		Currency:    act.Currency,
+		State:       model.FINALIZED.String(),
+		TxHash:      act.TxHash,
+		BlockHash:   act.BlockHash,
+		BlockNumber: act.BlockNumber,
+		StartDate:   time.Now().Local().Format(ctx.Time_FMT),
+	}
+	royalty.EndDate = royalty.StartDate
+	return royalty.Create(tx)
+}
+
+// QueryRoyalties pages the royalties earned by the creator
+func QueryRoyalties(creator string, pr paging.PageRequest) (paging.PagingResulter, error) {
+	db := ctx.GormDb.Model(&model.Activity{}).
+		Where("event_type = ? AND target = ?", model.ACT_ROYALTY.String(), creator)
+	return paging.MapList(pr, db.Session(&gorm.Session{}).Order("id DESC"), db.Session(&gorm.Session{}),
+		func(act model.Activity) dto.RoyaltyResp {
+			r := dto.RoyaltyResp{
+				TxHash:   act.TxHash,
+				FileHash: act.FileHash,
+				Buyer:    act.Source,
+				Date:     act.EndDate,
+			}
+			r.Amount, r.Currency = model.FormatPrice(act.Price, act.Currency)
+			return r
+		})
+}
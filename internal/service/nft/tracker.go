@@ -0,0 +1,440 @@
+package nft
+
+import (
+	"sync"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/panjf2000/ants/v2"
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+	"gorm.io/gorm"
+)
+
+var TRACK_POLL_INTERVAL = 6 * time.Second
+
+const (
+	TRACK_SWEEP_INTERVAL = 1 * time.Minute
+	// a tx not found on chain after this duration is regarded as dropped
+	TRACK_TIMEOUT = 10 * time.Minute
+	// the times a transient error is retried before the activity is left to the next sweep
+	TRACK_MAX_RETRIES = 5
+)
+
+// TxTracker resolves the tx of listening activities in the background,
+// the activity rows themselves are the persistent state of the tracker.
+type TxTracker struct {
+	pool     *ants.Pool
+	tracking sync.Map
+}
+
+var (
+	txTracker  *TxTracker
+	activities activityStore = dbStore{}
+)
+
+// activityStore keeps the activities the tracker resolves
+type activityStore interface {
+	// tracking returns the activities whose tx is not finalized yet
+	tracking() ([]model.Activity, error)
+	update(act *model.Activity) error
+	// finalize applies the finalized activity to the nft and records it, see finalizeActivity
+	finalize(act *model.Activity, split *purchaseSplit) error
+}
+
+// dbStore is the activity store in the database
+type dbStore struct{}
+
+func (dbStore) tracking() ([]model.Activity, error) {
+	return model.QueryNftEvents(ctx.GormDb, "state IN ?", model.TrackingStates())
+}
+
+func (dbStore) update(act *model.Activity) error {
+	return act.Update(ctx.GormDb)
+}
+
+func (dbStore) finalize(act *model.Activity, split *purchaseSplit) error {
+	return finalizeActivity(act, split)
+}
+
+func newTxTracker(size int) *TxTracker {
+	pool, err := ants.NewPool(size, ants.WithNonblocking(true))
+	if err != nil {
+		panic(err)
+	}
+	return &TxTracker{pool: pool}
+}
+
+// Track resolves the activity tx in the background, if the pool is full
+// the activity is left in listening state and picked up by the next sweep.
+func (t *TxTracker) Track(act model.Activity) {
+	if _, loaded := t.tracking.LoadOrStore(act.Id, struct{}{}); loaded {
+		return
+	}
+	err := t.pool.Submit(func() {
+		defer t.tracking.Delete(act.Id)
+		t.resolve(&act)
+	})
+	if err != nil {
+		t.tracking.Delete(act.Id)
+		logger.Error(err, "[Tx tracker] submit tracking task error", "activityId", act.Id)
+	}
+}
+
+// Resume tracks every activity whose tx is not finalized yet
+func (t *TxTracker) Resume() {
+	acts, err := activities.tracking()
+	if err != nil {
+		logger.Error(err, "[Tx tracker] query tracking activities error")
+		return
+	}
+	for _, act := range acts {
+		t.Track(act)
+	}
+}
+
+func (t *TxTracker) sweep() {
+	for {
+		time.Sleep(TRACK_SWEEP_INTERVAL)
+		t.Resume()
+	}
+}
+
+// resolve follows the tx of the activity through LISTENING -> IN_BLOCK -> FINALIZED,
+// the nft metadata is only changed once the tx is finalized, and a failed tx is only recorded
+// FAILED once its block is finalized. A tx whose block is reorged away ends in REORGED,
+// the indexer will apply it if it's finalized in another block later.
+func (t *TxTracker) resolve(act *model.Activity) {
+	logger := logger.WithName("txTracker").WithValues("activityId", act.Id, "eventType", act.EventType, "txHash", act.TxHash)
+	txHash, err := types.NewHashFromHexString(act.TxHash)
+	if err != nil {
+		logger.Error(err, "invalid tx hash of activity")
+		finishActivity(act, model.DROPPED)
+		return
+	}
+	deadline := activityStartTime(act).Add(TRACK_TIMEOUT)
+	submitted := act.SignedTx == "" || act.State == model.IN_BLOCK.String()
+	var after uint64
+	if act.State == model.IN_BLOCK.String() && act.BlockNumber > 0 {
+		// resumed, search from the including block so it isn't taken for reorged however old it is
+		after = act.BlockNumber - 1
+	}
+	retries := 0
+	for {
+		res, err := ctx.ChainClient.QueryTx(txHash, after)
+		if err != nil {
+			logger.Error(err, "query tx error")
+			if act.State == model.LISTENING.String() && time.Now().After(deadline) {
+				// leave it to the next sweep until the chain is reachable again
+				return
+			}
+			time.Sleep(TRACK_POLL_INTERVAL)
+			continue
+		}
+		switch res.Status {
+		case chain.TX_FAILED:
+			if res.Finalized {
+				logger.Error(res.Err, "tx failed")
+				act.Gas = formatFee(res.Fee)
+				act.BlockHash = res.Included.BlockHash.Hex()
+				act.BlockNumber = res.Included.BlockNumber
+				finishActivity(act, model.FAILED)
+				return
+			}
+			// the block the tx failed in may still be reorged away, it's only failed once finalized
+			fallthrough
+		case chain.TX_IN_BLOCK:
+			if act.State != model.IN_BLOCK.String() || act.BlockHash != res.Included.BlockHash.Hex() {
+				if err := matchActivityCall(act, &res.Included.Extrinsic); err != nil {
+					logger.Error(err, "the tx mismatch the activity")
+					finishActivity(act, model.FAILED)
+					return
+				}
+				act.Gas = formatFee(res.Fee)
+				act.BlockHash = res.Included.BlockHash.Hex()
+				act.BlockNumber = res.Included.BlockNumber
+				updateActivityState(act, model.IN_BLOCK)
+				logger.Info("tx in block", "blockHash", act.BlockHash)
+			}
+			// keep searching from the including block in case it's reorged
+			after = res.Included.BlockNumber - 1
+			time.Sleep(TRACK_POLL_INTERVAL)
+			continue
+		case chain.TX_FINALIZED:
+			if act.State != model.IN_BLOCK.String() {
+				if err := matchActivityCall(act, &res.Included.Extrinsic); err != nil {
+					logger.Error(err, "the tx mismatch the activity")
+					finishActivity(act, model.FAILED)
+					return
+				}
+			}
+			act.Gas = formatFee(res.Fee)
+			act.BlockHash = res.Included.BlockHash.Hex()
+			act.BlockNumber = res.Included.BlockNumber
+			var split *purchaseSplit
+			if act.EventType == model.ACT_TX.String() {
+				if split, err = verifyPurchasePayment(act, res.Included); err != nil {
+					if errors.Is(err, ERR_EVENTS_UNAVAILABLE) {
+						if retries++; retries > TRACK_MAX_RETRIES {
+							logger.Error(err, "verify purchase payment error, leave it to the next sweep")
+							return
+						}
+						logger.Error(err, "verify purchase payment error")
+						time.Sleep(TRACK_POLL_INTERVAL)
+						continue
+					}
+					logger.Error(err, "purchase rejected")
+					finishActivity(act, model.FAILED)
+					return
+				}
+			}
+			if err := activities.finalize(act, split); err != nil {
+				if errors.Is(err, model.ERR_VERSION_CONFLICT) {
+					logger.Info("the nft is changed meanwhile, apply the activity again")
+					continue
+				}
+				logger.Error(err, "apply activity to nft metadata error")
+				finishActivity(act, model.FAILED)
+				return
+			}
+			logger.Info("tx finalized", "blockHash", act.BlockHash)
+			return
+		}
+		if act.State == model.IN_BLOCK.String() {
+			logger.Info("the including block is reorged away", "blockHash", act.BlockHash)
+			finishActivity(act, model.REORGED)
+			return
+		}
+		after = res.BestNumber
+		if !submitted {
+			submitted = true
+			if _, err := ctx.ChainClient.SendTx1(act.SignedTx); err != nil {
+				// the failed tx is included, it's followed until its block is finalized
+				var de *chain.DispatchError
+				if !errors.As(err, &de) {
+					logger.Error(err, "send tx error")
+				}
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			logger.Info("tx dropped")
+			finishActivity(act, model.DROPPED)
+			return
+		}
+		time.Sleep(TRACK_POLL_INTERVAL)
+	}
+}
+
+func activityStartTime(act *model.Activity) time.Time {
+	t, err := time.ParseInLocation(ctx.Time_FMT, act.StartDate, time.Local)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+func finishActivity(act *model.Activity, state model.ActivityState) {
+	act.EndDate = time.Now().Local().Format(ctx.Time_FMT)
+	updateActivityState(act, state)
+}
+
+func updateActivityState(act *model.Activity, state model.ActivityState) {
+	act.State = state.String()
+	if err := activities.update(act); err != nil {
+		logger.Error(err, "[Tx tracker] update activity error", "activityId", act.Id)
+	}
+}
+
+// finalizeActivity applies the finalized activity to the nft and records it finalized in one transaction,
+// along with the royalty of a purchase. The nft changed meanwhile makes it fail with ERR_VERSION_CONFLICT.
+func finalizeActivity(act *model.Activity, split *purchaseSplit) error {
+	done := *act
+	done.State = model.FINALIZED.String()
+	done.EndDate = time.Now().Local().Format(ctx.Time_FMT)
+	err := ctx.GormDb.Transaction(func(tx *gorm.DB) error {
+		if done.EventType != model.ACT_BT.String() {
+			if err := applyActivity(tx, &done); err != nil {
+				return err
+			}
+		}
+		if err := done.Update(tx); err != nil {
+			return err
+		}
+		if split != nil {
+			return recordRoyalty(tx, &done, split)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*act = done
+	return nil
+}
+
+// applyActivity changes the nft metadata as the succeeded activity describes
+func applyActivity(tx *gorm.DB, act *model.Activity) error {
+	if act.EventType == model.ACT_MINT.String() {
+		return applyMint(tx, act)
+	}
+	evt, in, err := activityEvent(act)
+	if err != nil {
+		return err
+	}
+	nft, err := loadItem(tx, act.FileHash, act.NftToken)
+	if err != nil {
+		return err
+	}
+	if err := nft.fire(evt, in); err != nil {
+		return err
+	}
+	if evt == model.NFT_PURCHASE {
+		if err := closeReservation(tx, act.NftToken); err != nil {
+			return err
+		}
+	}
+	return nft.Update(tx)
+}
+
+// activityEvent is the event of the nft state machine the activity fires
+func activityEvent(act *model.Activity) (model.NftEvent, model.NftInput, error) {
+	in := model.NftInput{Actor: act.Creator, Price: act.Price, Currency: act.Currency, Token: act.NftToken}
+	switch act.EventType {
+	case model.ACT_MINT.String():
+		return model.NFT_MINT, in, nil
+	case model.ACT_TX.String():
+		in.Actor, in.Owner = act.Target, act.Target
+		return model.NFT_PURCHASE, in, nil
+	case model.ACT_TS.String():
+		in.Actor, in.Owner = act.Source, act.Target
+		return model.NFT_TRANSFER, in, nil
+	case model.ACT_MELT.String():
+		return model.NFT_MELT, in, nil
+	case model.ACT_ALT.String():
+		switch act.Target {
+		case model.LIST.String():
+			return model.NFT_LIST, in, nil
+		case model.MINT.String():
+			return model.NFT_UNLIST, in, nil
+		}
+		return model.NFT_REPRICE, in, nil
+	}
+	return "", in, errors.Errorf("unsupported event type: %s", act.EventType)
+}
+
+// applyMint marks the video minted, the tokens of an edition are created for the creator
+func applyMint(tx *gorm.DB, act *model.Activity) error {
+	nft := &model.VideoMetadata{FileHash: act.FileHash}
+	resp, err := nft.Get(tx)
+	if err != nil || len(resp) != 1 {
+		return errors.New("query nft metadata error")
+	}
+	nft = &resp[0]
+	if err := fireEvent(nft, model.NFT_MINT, model.NftInput{Token: act.NftToken}); err != nil {
+		return err
+	}
+	if isEdition(nft) {
+		if err := mintEdition(tx, nft); err != nil {
+			return err
+		}
+	}
+	return nft.Update(tx)
+}
+
+func tokenArg(token string) types.Bytes {
+	return types.NewBytes([]byte(token))
+}
+
+// priceArgs are the price args of Nft.list and Nft.update_price following the token,
+// the price in planck and the asset it's in, none for the chain token
+func priceArgs(price model.Money, currency string) ([]any, error) {
+	if price.IsNull() {
+		return nil, errors.New("no price to list the nft at")
+	}
+	c, err := model.LookupCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	asset := types.NewOptionU32Empty()
+	if !c.IsNative() {
+		asset = types.NewOptionU32(types.NewU32(c.AssetId))
+	}
+	return []any{types.NewU128(*price.Planck()), asset}, nil
+}
+
+func accountIdArg(walletAddress string) (*types.AccountID, error) {
+	_, pubkey, err := subkey.SS58Decode(walletAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid wallet address")
+	}
+	return types.NewAccountID(pubkey)
+}
+
+// matchActivityCall checks a client reported tx against the operation of the activity
+func matchActivityCall(act *model.Activity, ext *types.Extrinsic) error {
+	signer, err := accountIdArg(act.Signer)
+	if err != nil {
+		return err
+	}
+	expect := chain.ExpectedCall{Signer: signer[:], Args: []any{tokenArg(act.NftToken)}}
+	switch act.EventType {
+	case model.ACT_MINT.String():
+		nft := &model.VideoMetadata{FileHash: act.FileHash}
+		resp, err := nft.Get(ctx.GormDb)
+		if err != nil || len(resp) != 1 {
+			return errors.New("query nft metadata error")
+		}
+		expect = mintExpectedCall(signer, &resp[0])
+	case model.ACT_TX.String():
+		// the nft and the payment change hands at once
+		expect.CallName = chain.CALL_UTILITY_BATCH_ALL
+		expect.Calls = []chain.ExpectedCall{{CallName: chain.CALL_NFT_BUY, Args: expect.Args}}
+		expect.Args = nil
+	case model.ACT_MELT.String():
+		expect.CallName = chain.CALL_NFT_BURN
+	case model.ACT_TS.String():
+		to, err := accountIdArg(act.Target)
+		if err != nil {
+			return err
+		}
+		expect.CallName = chain.CALL_NFT_TRANSFER
+		expect.Args = append(expect.Args, *to)
+	case model.ACT_ALT.String():
+		switch {
+		case act.Target == model.LIST.String():
+			expect.CallName = chain.CALL_NFT_LIST
+		case act.Target == model.MINT.String():
+			expect.CallName = chain.CALL_NFT_UNLIST
+		default:
+			expect.CallName = chain.CALL_NFT_UPDATE_PRICE
+		}
+		if expect.CallName != chain.CALL_NFT_UNLIST {
+			args, err := priceArgs(act.Price, act.Currency)
+			if err != nil {
+				return err
+			}
+			expect.Args = append(expect.Args, args...)
+		}
+	default:
+		return errors.Errorf("unsupported event type: %s", act.EventType)
+	}
+	return ctx.ChainClient.MatchExpectedCall(ext, expect)
+}
+
+// mintExpectedCall is the mint of the video, every token of an edition is minted in one batch
+func mintExpectedCall(signer *types.AccountID, v *model.VideoMetadata) chain.ExpectedCall {
+	tokens := editionTokens(v)
+	if len(tokens) == 1 {
+		return chain.ExpectedCall{Signer: signer[:], CallName: chain.CALL_NFT_MINT, Args: []any{tokenArg(tokens[0])}}
+	}
+	expect := chain.ExpectedCall{Signer: signer[:], CallName: chain.CALL_UTILITY_BATCH_ALL}
+	for _, token := range tokens {
+		expect.Calls = append(expect.Calls, chain.ExpectedCall{CallName: chain.CALL_NFT_MINT, Args: []any{tokenArg(token)}})
+	}
+	return expect
+}
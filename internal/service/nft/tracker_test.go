@@ -0,0 +1,485 @@
+package nft
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"vdo-platform/internal/app/ctx"
+	"vdo-platform/internal/model"
+	"vdo-platform/pkg/chain"
+	"vdo-platform/pkg/setting"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func signFakeCall(t *testing.T, fc *chain.FakeChain, kr signature.KeyringPair, callName string, args ...any) *types.Extrinsic {
+	require := require.New(t)
+	call, err := fc.NewCall(callName, args...)
+	require.NoError(err)
+	return signFakeExtrinsic(t, fc, kr, call)
+}
+
+func signFakeExtrinsic(t *testing.T, fc *chain.FakeChain, kr signature.KeyringPair, call types.Call) *types.Extrinsic {
+	require := require.New(t)
+	nonce, err := fc.AccountNextIndex(kr.PublicKey)
+	require.NoError(err)
+	options, _, err := fc.MakeSignatureOptions(nonce)
+	require.NoError(err)
+	ext := types.NewExtrinsic(call)
+	require.NoError(ext.Sign(kr, options))
+	return &ext
+}
+
+func TestMatchActivityCall(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	alice := signature.TestKeyringPairAlice
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	bobId, err := accountIdArg(bob.Address)
+	require.NoError(err)
+
+	act := &model.Activity{
+		EventType: model.ACT_TS.String(),
+		NftToken:  "file-hash",
+		Signer:    alice.Address,
+		Source:    alice.Address,
+		Target:    bob.Address,
+	}
+	ext := signFakeCall(t, fc, alice, chain.CALL_NFT_TRANSFER, tokenArg(act.NftToken), *bobId)
+	require.NoError(matchActivityCall(act, ext))
+
+	// transferred to another account
+	act.Target = alice.Address
+	require.ErrorIs(matchActivityCall(act, ext), chain.ERR_TX_CALL_MISMATCH)
+
+	// signed by someone else
+	act.Target, act.Signer = bob.Address, bob.Address
+	require.ErrorIs(matchActivityCall(act, ext), chain.ERR_TX_SIGNER_MISMATCH)
+
+	// a signed tx is matched before it's bound to the activity
+	signed, err := codec.EncodeToHex(*ext)
+	require.NoError(err)
+	act.Target = alice.Address
+	require.ErrorIs(bindTx(act, TxData{Data: signed, Signer: alice.Address}), chain.ERR_TX_CALL_MISMATCH)
+	act.Target = bob.Address
+	require.ErrorIs(bindTx(act, TxData{Data: signed, Signer: bob.Address}), chain.ERR_TX_SIGNER_MISMATCH)
+	require.Empty(act.SignedTx)
+
+	list := &model.Activity{
+		EventType: model.ACT_ALT.String(),
+		NftToken:  "file-hash",
+		Signer:    alice.Address,
+		Source:    model.MINT.String(),
+		Target:    model.LIST.String(),
+		Price:     mustMoney(t, "1.5"),
+	}
+	planck := types.NewU128(*list.Price.Planck())
+	ext = signFakeCall(t, fc, alice, chain.CALL_NFT_LIST, tokenArg(list.NftToken), planck, types.NewOptionU32Empty())
+	require.NoError(matchActivityCall(list, ext))
+	// listed on chain at another price
+	list.Price = mustMoney(t, "1")
+	require.ErrorIs(matchActivityCall(list, ext), chain.ERR_TX_CALL_MISMATCH)
+	// or in another currency
+	model.AddAsset("USDT", 1984, 6)
+	defer delete(model.Assets, "USDT")
+	list.Price, list.Currency = model.NewMoney(planck.Int), "USDT"
+	require.ErrorIs(matchActivityCall(list, ext), chain.ERR_TX_CALL_MISMATCH)
+	ext = signFakeCall(t, fc, alice, chain.CALL_NFT_LIST, tokenArg(list.NftToken), planck, types.NewOptionU32(1984))
+	require.NoError(matchActivityCall(list, ext))
+	list.Target = "12.5"
+	require.ErrorIs(matchActivityCall(list, ext), chain.ERR_TX_CALL_MISMATCH)
+
+	melt := &model.Activity{
+		EventType: model.ACT_MELT.String(),
+		NftToken:  "file-hash",
+		Signer:    alice.Address,
+	}
+	ext = signFakeCall(t, fc, alice, chain.CALL_NFT_BURN, tokenArg(melt.NftToken))
+	require.NoError(matchActivityCall(melt, ext))
+	ext = signFakeCall(t, fc, alice, chain.CALL_NFT_BURN, tokenArg("another-hash"))
+	require.ErrorIs(matchActivityCall(melt, ext), chain.ERR_TX_CALL_MISMATCH)
+}
+
+func TestPurchaseSettlement(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	buyer := signature.TestKeyringPairAlice
+	seller, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	creator, err := signature.KeyringPairFromSecret("//Charlie", 42)
+	require.NoError(err)
+	platform, err := signature.KeyringPairFromSecret("//Dave", 42)
+	require.NoError(err)
+	ctx.Settings = &setting.Settings{Web3Setting: &setting.Web3SettingS{SupperAddress: platform.Address, PlatformFeeRate: 2}}
+	nft := &model.VideoMetadata{NftToken: "file-hash", Creator: creator.Address, Owner: seller.Address, Price: mustMoney(t, "1.5"), Royalty: 10}
+	act := &model.Activity{
+		EventType: model.ACT_TX.String(),
+		NftToken:  nft.NftToken,
+		Signer:    buyer.Address,
+		Source:    seller.Address,
+		Target:    buyer.Address,
+		Price:     nft.Price,
+	}
+
+	// the fake chain doesn't execute the batch, it pays what the test says
+	var paid []payment
+	fc.Dispatch = func(ext *types.Extrinsic) ([]*parser.Event, error) {
+		var evts []*parser.Event
+		for _, p := range paid {
+			from, _ := accountIdArg(buyer.Address)
+			to, _ := accountIdArg(p.To)
+			evts = append(evts, &parser.Event{
+				Name: chain.EVT_BALANCES_TRANSFER,
+				Fields: registry.DecodedFields{
+					chain.FakeAccountField("from", *from),
+					chain.FakeAccountField("to", *to),
+					chain.FakeBalanceField("amount", p.Amount),
+				},
+			})
+		}
+		return evts, nil
+	}
+	purchase := func() []*parser.Event {
+		call, _, err := purchaseCall(nft)
+		require.NoError(err)
+		ext := signFakeExtrinsic(t, fc, buyer, call)
+		require.NoError(matchActivityCall(act, ext))
+		hex, err := codec.EncodeToHex(*ext)
+		require.NoError(err)
+		txHashHex, err := fc.SendTx1(hex)
+		require.NoError(err)
+		txHash, err := types.NewHashFromHexString(txHashHex)
+		require.NoError(err)
+		res, err := fc.QueryTx(txHash, 0)
+		require.NoError(err)
+		evts, err := fc.BlockEvents(res.Included.BlockHash)
+		require.NoError(err)
+		return evts
+	}
+
+	split, err := splitPurchase(nft.Price.Planck(), model.NativeCurrency(), seller.Address, nft)
+	require.NoError(err)
+	require.Equal("1320000000000", split.SellerShare.String())
+	require.Equal("150000000000", split.Royalty.String())
+	require.Equal("30000000000", split.PlatformFee.String())
+
+	paid = split.payments()
+	require.Len(paid, 3)
+	require.NoError(checkPurchasePaid(split, buyer.Address, purchase(), 0))
+
+	// the royalty is left out
+	paid = paid[:1]
+	require.ErrorIs(checkPurchasePaid(split, buyer.Address, purchase(), 0), ERR_UNDERPAID)
+
+	// no royalty for the sale made by the creator
+	split, err = splitPurchase(nft.Price.Planck(), model.NativeCurrency(), creator.Address, nft)
+	require.NoError(err)
+	require.Zero(split.Royalty.Sign())
+	require.Len(split.payments(), 2)
+
+	// the purchase is verified against the shares kept when it's recorded
+	split, err = splitPurchase(nft.Price.Planck(), model.NativeCurrency(), seller.Address, nft)
+	require.NoError(err)
+	split.keep(act)
+	nft.Royalty, ctx.Settings.Web3Setting.PlatformFeeRate = 20, 5
+	kept, err := keptSplit(act, model.NativeCurrency(), creator.Address)
+	require.NoError(err)
+	require.Equal(split.payments(), kept.payments())
+
+	// a bare buy call doesn't pay the seller
+	ext := signFakeCall(t, fc, buyer, chain.CALL_NFT_BUY, tokenArg(act.NftToken))
+	require.ErrorIs(matchActivityCall(act, ext), chain.ERR_TX_CALL_MISMATCH)
+}
+
+func TestAssetPurchaseSettlement(t *testing.T) {
+	require := require.New(t)
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	ctx.ChainClient = fc
+	buyer := signature.TestKeyringPairAlice
+	seller, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	creator, err := signature.KeyringPairFromSecret("//Charlie", 42)
+	require.NoError(err)
+	platform, err := signature.KeyringPairFromSecret("//Dave", 42)
+	require.NoError(err)
+	ctx.Settings = &setting.Settings{Web3Setting: &setting.Web3SettingS{SupperAddress: platform.Address, PlatformFeeRate: 2}}
+	model.AddAsset("usdt", 1984, 6)
+	defer delete(model.Assets, "USDT")
+
+	usdt, err := model.LookupCurrency("USDT")
+	require.NoError(err)
+	price, err := usdt.Parse("10")
+	require.NoError(err)
+	nft := &model.VideoMetadata{NftToken: "file-hash", Creator: creator.Address, Owner: seller.Address, Price: price, Currency: usdt.Code(), Royalty: 10}
+
+	// every share is paid by a transfer of the asset
+	call, split, err := purchaseCall(nft)
+	require.NoError(err)
+	require.Equal("8800000", split.SellerShare.String())
+	require.Equal("1000000", split.Royalty.String())
+	require.Equal("200000", split.PlatformFee.String())
+	for _, p := range split.payments() {
+		pay, err := split.transferCall(p)
+		require.NoError(err)
+		require.Equal(fc.CallIndex(chain.CALL_ASSETS_TRANSFER), pay.CallIndex)
+		b, err := codec.Encode(pay)
+		require.NoError(err)
+		require.True(bytes.Contains(call.Args, b))
+	}
+
+	transfers := func(name string, assetId uint32) []*parser.Event {
+		var evts []*parser.Event
+		from, _ := accountIdArg(buyer.Address)
+		for _, p := range split.payments() {
+			to, _ := accountIdArg(p.To)
+			fields := registry.DecodedFields{
+				chain.FakeAccountField("from", *from),
+				chain.FakeAccountField("to", *to),
+				chain.FakeBalanceField("amount", p.Amount),
+			}
+			if name == chain.EVT_ASSETS_TRANSFERRED {
+				fields = append(fields, &registry.DecodedField{Name: "asset_id", Value: types.NewU32(assetId)})
+			}
+			evts = append(evts, &parser.Event{Name: name, Phase: &types.Phase{IsApplyExtrinsic: true}, Fields: fields})
+		}
+		return evts
+	}
+	require.NoError(checkPurchasePaid(split, buyer.Address, transfers(chain.EVT_ASSETS_TRANSFERRED, 1984), 0))
+	// paid in the chain token or another asset
+	require.ErrorIs(checkPurchasePaid(split, buyer.Address, transfers(chain.EVT_BALANCES_TRANSFER, 0), 0), ERR_UNDERPAID)
+	require.ErrorIs(checkPurchasePaid(split, buyer.Address, transfers(chain.EVT_ASSETS_TRANSFERRED, 1), 0), ERR_UNDERPAID)
+
+}
+
+func TestVerifyPurchaseUnavailableEvents(t *testing.T) {
+	require := require.New(t)
+	ctx.ChainClient = chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	act := &model.Activity{EventType: model.ACT_TX.String(), NftToken: "file-hash"}
+	// the events are fetched before anything else, so the error is left to retry
+	_, err := verifyPurchasePayment(act, &chain.IncludedExtrinsic{BlockHash: types.Hash{1}})
+	require.ErrorIs(err, ERR_EVENTS_UNAVAILABLE)
+}
+
+// memStore keeps the tracked activities in memory, finalizing an activity
+// fails with ERR_VERSION_CONFLICT as many times as conflicts
+type memStore struct {
+	lock      sync.Mutex
+	acts      map[int64]model.Activity
+	conflicts int
+	finalized int
+}
+
+func (t *memStore) tracking() ([]model.Activity, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var res []model.Activity
+	for _, act := range t.acts {
+		for _, state := range model.TrackingStates() {
+			if act.State == state {
+				res = append(res, act)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (t *memStore) update(act *model.Activity) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.acts[act.Id] = *act
+	return nil
+}
+
+func (t *memStore) finalize(act *model.Activity, split *purchaseSplit) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.finalized++
+	if t.conflicts > 0 {
+		t.conflicts--
+		return model.ERR_VERSION_CONFLICT
+	}
+	act.State = model.FINALIZED.String()
+	act.EndDate = time.Now().Local().Format(ctx.Time_FMT)
+	t.acts[act.Id] = *act
+	return nil
+}
+
+func (t *memStore) get(id int64) model.Activity {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.acts[id]
+}
+
+func (t *memStore) waitState(tt *testing.T, id int64, state model.ActivityState) {
+	require.Eventually(tt, func() bool { return t.get(id).State == state.String() }, time.Second, time.Millisecond)
+}
+
+func setupTracker(t *testing.T) (*memStore, *chain.FakeChain) {
+	store := &memStore{acts: make(map[int64]model.Activity)}
+	interval := TRACK_POLL_INTERVAL
+	TRACK_POLL_INTERVAL = 5 * time.Millisecond
+	activities = store
+	t.Cleanup(func() { activities, TRACK_POLL_INTERVAL = dbStore{}, interval })
+	fc := chain.NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	fc.AutoFinalize = false
+	ctx.ChainClient = fc
+	// no tx is included in the first block, searching from the block before it means no block to search from
+	fc.EmitEvents()
+	return store, fc
+}
+
+// unlistActivity is the activity of alice unlisting her nft with the signed tx, tracked since start
+func unlistActivity(t *testing.T, store *memStore, fc *chain.FakeChain, start time.Time) (*model.Activity, string) {
+	require := require.New(t)
+	alice := signature.TestKeyringPairAlice
+	ext := signFakeCall(t, fc, alice, chain.CALL_NFT_UNLIST, tokenArg("file-hash"))
+	signtx, err := codec.EncodeToHex(*ext)
+	require.NoError(err)
+	txHash, err := chain.SignedTxHash(signtx)
+	require.NoError(err)
+	act := &model.Activity{
+		Id:        1,
+		EventType: model.ACT_ALT.String(),
+		Source:    model.LIST.String(),
+		Target:    model.MINT.String(),
+		FileHash:  "file-hash",
+		NftToken:  "file-hash",
+		State:     model.LISTENING.String(),
+		Signer:    alice.Address,
+		TxHash:    txHash,
+		StartDate: start.Local().Format(ctx.Time_FMT),
+	}
+	require.NoError(store.update(act))
+	return act, signtx
+}
+
+func queryTx(t *testing.T, fc *chain.FakeChain, txHashHex string) *chain.TxResult {
+	txHash, err := types.NewHashFromHexString(txHashHex)
+	require.NoError(t, err)
+	res, err := fc.QueryTx(txHash, 0)
+	require.NoError(t, err)
+	return res
+}
+
+func TestResolveFinalized(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	// sent by the client
+	_, err := fc.SendTx1(signtx)
+	require.NoError(err)
+
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.IN_BLOCK)
+	included := queryTx(t, fc, act.TxHash).Included
+	require.Equal(included.BlockHash.Hex(), store.get(act.Id).BlockHash)
+	require.Equal(included.BlockNumber, store.get(act.Id).BlockNumber)
+	require.Zero(store.finalized)
+
+	fc.Finalize()
+	store.waitState(t, act.Id, model.FINALIZED)
+	require.Equal(1, store.finalized)
+}
+
+func TestResolveFailedOnceFinalized(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	fc.Dispatch = func(ext *types.Extrinsic) ([]*parser.Event, error) {
+		return nil, errors.New("Nft.NotOwner")
+	}
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	_, err := fc.SendTx1(signtx)
+	var de *chain.DispatchError
+	require.ErrorAs(err, &de)
+
+	// the block the tx failed in may still be reorged away
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.IN_BLOCK)
+	fc.Finalize()
+	store.waitState(t, act.Id, model.FAILED)
+	require.Zero(store.finalized)
+}
+
+func TestResolveReorged(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	_, err := fc.SendTx1(signtx)
+	require.NoError(err)
+
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.IN_BLOCK)
+	require.NoError(fc.Reorg(store.get(act.Id).BlockNumber - 1))
+	store.waitState(t, act.Id, model.REORGED)
+	require.Zero(store.finalized)
+}
+
+func TestResolveDropped(t *testing.T) {
+	store, fc := setupTracker(t)
+	// never sent by the client
+	act, _ := unlistActivity(t, store, fc, time.Now().Add(-TRACK_TIMEOUT-time.Minute))
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.DROPPED)
+	require.Equal(t, chain.TX_PENDING, queryTx(t, fc, act.TxHash).Status)
+}
+
+func TestResolveSubmitsSignedTx(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	fc.AutoFinalize = true
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	act.SignedTx = signtx
+	require.NoError(store.update(act))
+
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.FINALIZED)
+	require.Equal(chain.TX_FINALIZED, queryTx(t, fc, act.TxHash).Status)
+}
+
+func TestResolveResumed(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	_, err := fc.SendTx1(signtx)
+	require.NoError(err)
+	included := queryTx(t, fc, act.TxHash).Included
+	// left in block by the last run, long enough ago the block is out of the recent ones
+	act.State = model.IN_BLOCK.String()
+	act.BlockHash, act.BlockNumber = included.BlockHash.Hex(), included.BlockNumber
+	require.NoError(store.update(act))
+	for i := 0; i < 150; i++ {
+		fc.EmitEvents()
+	}
+
+	newTxTracker(1).Resume()
+	time.Sleep(20 * TRACK_POLL_INTERVAL)
+	require.Equal(model.IN_BLOCK.String(), store.get(act.Id).State)
+	fc.Finalize()
+	store.waitState(t, act.Id, model.FINALIZED)
+}
+
+func TestResolveVersionConflict(t *testing.T) {
+	require := require.New(t)
+	store, fc := setupTracker(t)
+	fc.AutoFinalize = true
+	store.conflicts = 2
+	act, signtx := unlistActivity(t, store, fc, time.Now())
+	_, err := fc.SendTx1(signtx)
+	require.NoError(err)
+
+	// applied again every time the nft is changed meanwhile
+	newTxTracker(1).Track(*act)
+	store.waitState(t, act.Id, model.FINALIZED)
+	require.Equal(3, store.finalized)
+}
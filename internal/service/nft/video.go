@@ -5,6 +5,7 @@ import (
 	"mime/multipart"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"vdo-platform/pkg/utils"
 
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 var threshold = 40
@@ -22,6 +24,7 @@ const (
 	FILE_STATUS_PENDING = "pending"
 	FILE_STATUS_ACTIVE  = "active"
 	FILE_STATUS_CANCEL  = "cancel"
+	FILE_STATUS_DELETE  = "delete"
 )
 
 const COVER_IMAGE_PATH = "./cover_images/"
@@ -38,20 +41,95 @@ func QueryVideosByQuerier(querier dto.Querier) (dto.Responder, error) {
 		list      []model.VideoMetadata
 		count     int64
 	)
-	err := dto.UniversalQuery(ctx.GormDb, querier, &list, &count)
+	querier, err := priceInPlanckFilter(querier)
+	if err != nil {
+		return responder, err
+	}
+	err = dto.UniversalQuery(visibleVideos(querier), querier, &list, &count)
 	if err != nil {
 		return responder, errors.Wrap(err, "query videos error")
 	}
 	if count != int64(len(list)) {
 		count = int64(len(list))
 	}
-	responder, err = dto.UniversalLoader(querier.Field, list, count)
+	res := make([]dto.VideoResp, 0, len(list))
+	for _, v := range list {
+		res = append(res, videoResp(v))
+	}
+	responder, err = dto.UniversalLoader(querier.Field, res, count)
 	if err != nil {
 		return responder, errors.Wrap(err, "query videos error")
 	}
 	return responder, nil
 }
 
+// priceInPlanckFilter converts the prices the videos are filtered by to planck of the currency
+// filtered by, the chain token if none. The price column keeps the amounts in planck to be
+// compared numerically, the currency column keeps empty for the chain token.
+func priceInPlanckFilter(querier dto.Querier) (dto.Querier, error) {
+	filter := make([]dto.FilterItem, len(querier.Filter))
+	currency := model.NativeCurrency()
+	for i, item := range querier.Filter {
+		filter[i] = item
+		if strings.ToLower(item.Column) != "currency" {
+			continue
+		}
+		values := make([]any, len(item.Values))
+		for j, v := range item.Values {
+			symbol, ok := v.(string)
+			if !ok {
+				return querier, errors.Errorf("invalid currency filter: %v", v)
+			}
+			c, err := model.LookupCurrency(symbol)
+			if err != nil {
+				return querier, err
+			}
+			values[j] = c.Code()
+			if len(item.Values) == 1 {
+				currency = c
+			}
+		}
+		filter[i].Values = values
+	}
+	for i, item := range filter {
+		if strings.ToLower(item.Column) != "price" {
+			continue
+		}
+		values := make([]any, len(item.Values))
+		for j, v := range item.Values {
+			var amount string
+			switch v := v.(type) {
+			case string:
+				amount = v
+			case float64:
+				amount = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return querier, errors.Errorf("invalid price filter: %v", v)
+			}
+			money, err := currency.Parse(amount)
+			if err != nil || money.IsNull() {
+				return querier, errors.Errorf("invalid price filter: %v", v)
+			}
+			values[j] = money.Planck().String()
+		}
+		filter[i].Values = values
+	}
+	querier.Filter = filter
+	return querier, nil
+}
+
+// visibleVideos leaves the melted videos out unless the querier filters by the nft status
+func visibleVideos(querier dto.Querier) *gorm.DB {
+	for _, item := range querier.Filter {
+		if item.Column == "nft_status" {
+			return ctx.GormDb
+		}
+	}
+	// a subquery, so the filters joined by OR can't bring them back
+	visible := ctx.GormDb.Model(&model.VideoMetadata{}).Where("nft_status <> ?", model.MELT.String())
+	return ctx.GormDb.Table("(?) AS video_metadata", visible)
+}
+
 func QueryRelatedVideos(searcher dto.Searcher) (dto.Responder, error) {
 	var (
 		v     model.VideoMetadata
@@ -75,6 +153,9 @@ func QueryRelatedVideos(searcher dto.Searcher) (dto.Responder, error) {
 	}
 	matcher := utils.NewStringMatcher(strings.Split(searcher.Key, " "))
 	for _, v := range videos {
+		if v.NftStatus == model.MELT.String() {
+			continue
+		}
 		switch searcher.Type {
 		case "name":
 			text = v.FileName
@@ -98,7 +179,7 @@ func UpdateVideoStatus(filehash, status string) {
 	//check metadata is exsited
 	video := &model.VideoMetadata{FileHash: filehash}
 	res, err := video.Get(ctx.GormDb)
-	if err != nil {
+	if err != nil || len(res) == 0 {
 		return
 	}
 	video = &res[0]
@@ -107,6 +188,8 @@ func UpdateVideoStatus(filehash, status string) {
 		status = model.SCHEDULE.String()
 	case FILE_STATUS_ACTIVE:
 		status = model.STORAGE.String()
+	case FILE_STATUS_DELETE:
+		status = model.DELETE.String()
 	default:
 		status = model.SCHEDULE.String()
 	}
@@ -129,8 +212,7 @@ func UpdateVideoStatus(filehash, status string) {
 		return
 	}
 	//update video metadata
-	video.FileStatus = status
-	video.Update(ctx.GormDb)
+	video.UpdateFileStatus(ctx.GormDb, status)
 }
 
 func SaveCoverImg(file *multipart.FileHeader, filename string) error {
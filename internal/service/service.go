@@ -5,6 +5,7 @@ import (
 	"vdo-platform/internal/model"
 	"vdo-platform/internal/service/account"
 	"vdo-platform/internal/service/auth"
+	"vdo-platform/internal/service/faucet"
 	"vdo-platform/internal/service/nft"
 	"vdo-platform/pkg/log"
 
@@ -17,6 +18,7 @@ func Setup() {
 	autoMigrate(ctx.GormDb)
 
 	AccountService = account.NewService(ctx.GormDb, ctx.Settings.Web3Setting.ChainId, log.Logger)
+	faucet.Setup(ctx.Settings, log.Logger)
 	auth.Setup(ctx.Settings, AccountService, log.Logger)
 	nft.Setup()
 }
@@ -0,0 +1,39 @@
+package chain
+
+import (
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// Backend is what the services need from the chain, ChainClient talks to the nodes
+// while FakeChain keeps a deterministic chain in memory for the offline tests.
+type Backend interface {
+	// TransferBySs58Address transfers tokens from the platform account, the amount is in units of token
+	TransferBySs58Address(target string, amount *big.Int) (*types.Hash, *types.Hash, error)
+	AccountNextIndex(accountPubKey []byte) (uint64, error)
+	GetAccountBalance(accountPubKey []byte) (*AccountBalance, error)
+	// GetAssetBalance returns the balance of the pallet asset held by the account in the smallest unit
+	GetAssetBalance(assetId uint32, accountPubKey []byte) (*big.Int, error)
+	MakeSignatureOptions(nonce uint64) (types.SignatureOptions, *EraWindow, error)
+	// SendTx1 submits a hex encoded signed extrinsic and waits until it is included in a block
+	SendTx1(signtx string) (string, error)
+	QueryTx(txHash types.Hash, after uint64) (*TxResult, error)
+	EstimateFee(extHex string) (*FeeInfo, error)
+	NewCall(callName string, args ...any) (types.Call, error)
+	MatchExpectedCall(ext *types.Extrinsic, expect ExpectedCall) error
+	BlockEvents(blockHash types.Hash) ([]*parser.Event, error)
+	NewBlockFollower(store HeightStore, handler BlockHandler) Follower
+	TokenDecimals() uint32
+}
+
+type Follower interface {
+	// Run follows the chain until the process exits
+	Run()
+}
+
+var (
+	_ Backend = (*ChainClient)(nil)
+	_ Backend = (*FakeChain)(nil)
+)
@@ -17,6 +17,10 @@
 package chain
 
 import (
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
 	"github.com/pkg/errors"
@@ -37,7 +41,7 @@ func (c *ChainClient) GetSyncStatus() (bool, error) {
 	if !c.IsChainClientOk() {
 		return false, ERR_RPC_CONNECTION
 	}
-	h, err := c.api.RPC.System.Health()
+	h, err := c.api().RPC.System.Health()
 	if err != nil {
 		return false, err
 	}
@@ -46,7 +50,7 @@ func (c *ChainClient) GetSyncStatus() (bool, error) {
 
 func (c *ChainClient) GetChainMethodList() []string {
 	methods := make([]string, 0)
-	for _, v := range c.metadata.AsMetadataV8.Modules {
+	for _, v := range c.metadata().AsMetadataV8.Modules {
 		methods = append(methods, string(v.Name))
 	}
 	return methods
@@ -68,7 +72,7 @@ func (c *ChainClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		c.metadata(),
 		pallet_System,
 		account,
 		b,
@@ -77,7 +81,7 @@ func (c *ChainClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.api().RPC.State.GetStorageLatest(key, &data)
 	if err != nil {
 		return data, errors.Wrap(err, "[GetStorageLatest]")
 	}
@@ -94,3 +98,108 @@ func (c *ChainClient) GetAccountNonce(accountPubKey []byte) (uint64, error) {
 	}
 	return uint64(accountInfo.Nonce), nil
 }
+
+// AccountBalance is the balance of an account in the smallest unit
+type AccountBalance struct {
+	Free     *big.Int
+	Reserved *big.Int
+	Frozen   *big.Int
+	Nonce    uint64
+}
+
+// Transferable is the part of the free balance that is not frozen
+func (t *AccountBalance) Transferable() *big.Int {
+	n := new(big.Int).Sub(t.Free, t.Frozen)
+	if n.Sign() < 0 {
+		n.SetInt64(0)
+	}
+	return n
+}
+
+// GetAccountBalance returns the balance of the account, a nonexistent account has a zero balance
+func (c *ChainClient) GetAccountBalance(accountPubKey []byte) (*AccountBalance, error) {
+	info, err := c.GetAccountInfo(accountPubKey)
+	if err != nil && err != ERR_RPC_EMPTY_VALUE {
+		return nil, err
+	}
+	return &AccountBalance{
+		Free:     u128Int(info.Data.Free),
+		Reserved: u128Int(info.Data.Reserved),
+		Frozen:   u128Int(info.Data.MiscFrozen),
+		Nonce:    uint64(info.Nonce),
+	}, nil
+}
+
+// assetAccount is the head of an Assets.Account entry, the fields after the balance are left undecoded
+type assetAccount struct {
+	Balance types.U128
+}
+
+// GetAssetBalance returns the balance of the asset, zero if the account holds none
+func (c *ChainClient) GetAssetBalance(assetId uint32, accountPubKey []byte) (*big.Int, error) {
+	id, err := codec.Encode(types.NewU32(assetId))
+	if err != nil {
+		return nil, errors.Wrap(err, "[EncodeToBytes]")
+	}
+	a, err := types.NewAccountID(accountPubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "[NewAccountID]")
+	}
+	b, err := codec.Encode(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "[EncodeToBytes]")
+	}
+	key, err := types.CreateStorageKey(c.metadata(), pallet_Assets, account, id, b)
+	if err != nil {
+		return nil, errors.Wrap(err, "[CreateStorageKey]")
+	}
+	var data assetAccount
+	ok, err := c.api().RPC.State.GetStorageLatest(key, &data)
+	if err != nil {
+		return nil, errors.Wrap(err, "[GetStorageLatest]")
+	}
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return u128Int(data.Balance), nil
+}
+
+func u128Int(n types.U128) *big.Int {
+	if n.Int == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(n.Int)
+}
+
+func (c *ChainClient) TokenDecimals() uint32 {
+	return c.tokenDecimals
+}
+
+// FormatBalance formats the amount in the smallest unit as the amount of tokens
+func FormatBalance(amount *big.Int, decimals uint32) string {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	s := new(big.Rat).SetFrac(amount, unit).FloatString(int(decimals))
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// BlockEvents returns the events emitted in the block
+func (c *ChainClient) BlockEvents(blockHash types.Hash) ([]*parser.Event, error) {
+	return c.retriver().GetEvents(blockHash)
+}
+
+// ParseBalance parses the amount of tokens into the smallest unit, it can't be more precise than the decimals
+func ParseBalance(amount string, decimals uint32) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || r.Sign() < 0 {
+		return nil, errors.Errorf("invalid amount: %s", amount)
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	r.Mul(r, new(big.Rat).SetInt(unit))
+	if !r.IsInt() {
+		return nil, errors.Errorf("amount %s has more than %d decimals", amount, decimals)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
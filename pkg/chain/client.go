@@ -19,11 +19,12 @@ package chain
 import (
 	"encoding/json"
 	"math/big"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/retriever"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/state"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/rpc/author"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -35,31 +36,48 @@ import (
 )
 
 type ChainClient struct {
-	api             *gsrpc.SubstrateAPI
-	metadata        *types.Metadata
-	runtimeVersion  *types.RuntimeVersion
+	conn            atomic.Pointer[chainConn]
+	rpcAddrs        []string
+	endpointIdx     int
+	reconnectLock   sync.Mutex
 	genesisHash     types.Hash
 	keyring         signature.KeyringPair
-	rpcAddr         string
 	timeForBlockOut time.Duration
 	networkId       uint16
 	tokenDecimals   uint32
-	retriver        retriever.EventRetriever
+	eraPeriod       uint64
+	nonces          nonceManager
 }
 
-func NewChainClient(rpcAddr, secret string, networkId uint16) (*ChainClient, error) {
-	api, err := gsrpc.NewSubstrateAPI(rpcAddr)
-	if err != nil {
-		return nil, err
+// NewChainClient connects to the first available endpoint of rpcAddrs,
+// the others are used in turn when the active one fails.
+func NewChainClient(rpcAddrs []string, secret string, networkId uint16) (*ChainClient, error) {
+	if len(rpcAddrs) == 0 {
+		return nil, errors.New("empty rpc endpoints")
 	}
-	retv, err := retriever.NewDefaultEventRetriever(state.NewEventProvider(api.RPC.State), api.RPC.State)
-	if err != nil {
-		return nil, err
+	cli := ChainClient{
+		rpcAddrs:        rpcAddrs,
+		networkId:       networkId,
+		timeForBlockOut: 15 * time.Second,
+		eraPeriod:       DEFAULT_ERA_PERIOD,
+	}
+	var (
+		conn *chainConn
+		err  error
+	)
+	for i, addr := range rpcAddrs {
+		conn, err = dialChainConn(addr)
+		if err == nil {
+			cli.endpointIdx = i
+			break
+		}
+		logger.Error(err, "connect rpc endpoint error", "endpoint", addr)
 	}
-	metadata, err := api.RPC.State.GetMetadataLatest()
 	if err != nil {
 		return nil, err
 	}
+	cli.conn.Store(conn)
+	api := conn.api
 	props, err := api.RPC.System.Properties()
 	if err != nil {
 		return nil, err
@@ -68,10 +86,6 @@ func NewChainClient(rpcAddr, secret string, networkId uint16) (*ChainClient, err
 	if err != nil {
 		return nil, err
 	}
-	runtimeVersion, err := api.RPC.State.GetRuntimeVersionLatest()
-	if err != nil {
-		return nil, err
-	}
 	keyring, err := signature.KeyringPairFromSecret(secret, 0)
 	if err != nil {
 		return nil, err
@@ -81,45 +95,75 @@ func NewChainClient(rpcAddr, secret string, networkId uint16) (*ChainClient, err
 	if tokenDecimals <= 0 {
 		tokenDecimals = 18
 	}
-	cli := ChainClient{
-		api:             api,
-		metadata:        metadata,
-		genesisHash:     genesisHash,
-		runtimeVersion:  runtimeVersion,
-		keyring:         keyring,
-		retriver:        retv,
-		rpcAddr:         rpcAddr,
-		networkId:       networkId,
-		tokenDecimals:   tokenDecimals,
-		timeForBlockOut: 15 * time.Second,
-	}
+	cli.genesisHash = genesisHash
+	cli.keyring = keyring
+	cli.tokenDecimals = tokenDecimals
+	go cli.keepAlive()
+	go cli.watchRuntimeUpgrade()
 	return &cli, nil
 }
 
+// IsChainClientOk checks the active connection and fails over to the other endpoints if it's broken
 func (c *ChainClient) IsChainClientOk() bool {
-	err := healthchek(c.api)
-	if err != nil {
-		c.api = nil
-		cli, err := reconnectChainClient(c.rpcAddr)
-		if err != nil {
-			return false
-		}
-		c.api = cli
+	if healthchek(c.api()) == nil {
 		return true
 	}
-	return true
+	return c.failover() == nil
 }
 
-func (c *ChainClient) MakeSignatureOptions(nonce uint64) types.SignatureOptions {
+// SetEraPeriod changes the number of blocks the signed extrinsics stay valid for,
+// it's rounded up to a power of two in [4, 65536].
+func (c *ChainClient) SetEraPeriod(period uint64) {
+	if period > 0 {
+		c.eraPeriod = period
+	}
+}
+
+// MakeSignatureOptions makes the options to sign a mortal extrinsic checkpointed at the latest
+// finalized block, the returned window tells in which blocks the extrinsic can be included.
+func (c *ChainClient) MakeSignatureOptions(nonce uint64) (types.SignatureOptions, *EraWindow, error) {
+	conn := c.conn.Load()
+	era, checkpoint, window, err := c.mortalCheckpoint(conn)
+	if err != nil {
+		return types.SignatureOptions{}, nil, err
+	}
 	return types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		BlockHash:          checkpoint,
+		Era:                era,
 		GenesisHash:        c.genesisHash,
 		Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
+		SpecVersion:        conn.runtimeVersion.SpecVersion,
 		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
+		TransactionVersion: conn.runtimeVersion.TransactionVersion,
+	}, window, nil
+}
+
+func (c *ChainClient) mortalCheckpoint(conn *chainConn) (types.ExtrinsicEra, types.Hash, *EraWindow, error) {
+	api := conn.api
+	finalizedHash, err := api.RPC.Chain.GetFinalizedHead()
+	if err != nil {
+		return types.ExtrinsicEra{}, types.Hash{}, nil, errors.Wrap(err, "get finalized head error")
+	}
+	header, err := api.RPC.Chain.GetHeader(finalizedHash)
+	if err != nil {
+		return types.ExtrinsicEra{}, types.Hash{}, nil, errors.Wrap(err, "get finalized header error")
 	}
+	current := uint64(header.Number)
+	era := newMortalEra(c.eraPeriod, current)
+	window := era.window(current)
+	checkpoint := finalizedHash
+	if window.Birth != current {
+		// the phase was quantized, the era begins at an earlier block
+		if checkpoint, err = api.RPC.Chain.GetBlockHash(window.Birth); err != nil {
+			return types.ExtrinsicEra{}, types.Hash{}, nil, errors.Wrap(err, "get checkpoint block hash error")
+		}
+	}
+	return era.encode(), checkpoint, &window, nil
+}
+
+// NewCall makes the call with the metadata of the current runtime
+func (c *ChainClient) NewCall(callName string, args ...any) (types.Call, error) {
+	return types.NewCall(c.metadata(), callName, args...)
 }
 
 func (c *ChainClient) NewAccountId(pubkey []byte) (*types.AccountID, error) {
@@ -159,7 +203,7 @@ func (c *ChainClient) doTransfer(target multiTargetAddr, amount *big.Int) (*type
 	if err != nil {
 		return nil, nil, err
 	}
-	call, err := types.NewCall(c.metadata, "Balances.transfer", targetAddr, types.NewUCompact(amount))
+	call, err := types.NewCall(c.metadata(), CALL_BALANCES_TRANSFER, targetAddr, types.NewUCompact(amount))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -180,111 +224,183 @@ type LoggerContexedExtrinsicSubmiter struct {
 
 func (t *LoggerContexedExtrinsicSubmiter) submitAndWatchExtrinsicUtilSuccess(call types.Call) (*types.Hash, *types.Hash, error) {
 	logger := t.logger
-	defer func() {
-		t.logger = logger
-	}()
 	c := t.cc
-	api := c.api
-	accountKey, err := types.CreateStorageKey(c.metadata, "System", "Account", c.keyring.PublicKey)
+	api := c.api()
+	// the submissions of the signer are serialized until the extrinsic is accepted by the pool
+	signer := c.nonces.acquire(c.keyring.PublicKey)
+	nonce, err := c.nextNonce(signer, c.keyring.PublicKey)
 	if err != nil {
+		signer.Unlock()
 		return nil, nil, err
 	}
-	var accountInfo types.AccountInfo
-	ok, err := api.RPC.State.GetStorageLatest(accountKey, &accountInfo)
-	if err != nil {
-		return nil, nil, err
-	}
-	var nonce uint32
-	if !ok {
-		nonce = 1
-	} else {
-		nonce = uint32(accountInfo.Nonce)
-	}
 
 	var sub *author.ExtrinsicStatusSubscription
 	var txHash *types.Hash
+	refreshed := false
 	for i := 0; i < 3; i++ {
-		options := types.SignatureOptions{
-			BlockHash:          c.genesisHash,
-			GenesisHash:        c.genesisHash,
-			Era:                types.ExtrinsicEra{IsMortalEra: false},
-			Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-			Tip:                types.NewUCompactFromUInt(0),
-			SpecVersion:        c.runtimeVersion.SpecVersion,
-			TransactionVersion: c.runtimeVersion.TransactionVersion,
+		var (
+			options types.SignatureOptions
+			window  *EraWindow
+		)
+		options, window, err = c.MakeSignatureOptions(nonce)
+		if err != nil {
+			break
 		}
+		logger.V(1).Info("sign the call", "nonce", nonce, "eraBirth", window.Birth, "eraDeath", window.Death)
 
 		ext := types.NewExtrinsic(call)
 		err = ext.Sign(c.keyring, options)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "sign a call error")
+			err = errors.Wrap(err, "sign a call error")
+			break
 		}
 		txHash, err = figureExtrinsicHash(&ext)
 		if err != nil {
-			return nil, nil, err
+			break
 		}
 
 		sub, err = api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+		if isOutdatedRuntimeError(err) && !refreshed {
+			// retry once with the runtime version after an upgrade
+			refreshed = true
+			if changed, rerr := c.refreshRuntime(); rerr == nil && changed {
+				logger.Info("runtime refreshed, sign the call again")
+				api = c.api()
+				continue
+			}
+		}
+		if isStaleNonceError(err) {
+			// the nonce is taken by others, e.g. the txs sent by another process with the same account
+			if n, nerr := c.AccountNextIndex(c.keyring.PublicKey); nerr == nil {
+				logger.Error(err, "nonce reconciled, try again", "nonce", n)
+				nonce = n
+				continue
+			}
+		}
 		if err != nil {
-			nonce++
 			logger.Error(err, "try again later", "nonce", nonce)
 			time.Sleep(3 * time.Second)
+			api = c.api()
 			continue
 		}
 		break
 	}
-	defer func() {
-		if sub != nil {
-			sub.Unsubscribe()
-		}
-	}()
-	logger = logger.WithValues("txHash", txHash)
-	const timeoutSecs = 18
-	timeout := time.After(timeoutSecs * time.Second)
+	if err != nil {
+		signer.synced = false
+		signer.Unlock()
+		return nil, txHash, errors.Wrap(err, "submit extrinsic error")
+	}
+	signer.next, signer.synced = nonce+1, true
+	signer.Unlock()
+
+	blockHash, err := t.watchExtrinsic(sub, *txHash, false)
+	if err != nil && blockHash == nil {
+		// dropped by the pool, the nonces after it can never be included
+		c.nonces.invalidate(c.keyring.PublicKey)
+	}
+	return blockHash, txHash, err
+}
+
+const (
+	inBlockTimeoutSecs   = 18
+	finalizedTimeoutSecs = 60
+)
+
+// watchExtrinsic waits on the status subscription until the extrinsic is included
+// in a block, or finalized when untilFinalized is set, and then checks its dispatch result.
+func (t *LoggerContexedExtrinsicSubmiter) watchExtrinsic(sub *author.ExtrinsicStatusSubscription, txHash types.Hash, untilFinalized bool) (*types.Hash, error) {
+	defer sub.Unsubscribe()
+	logger := t.logger.WithValues("txHash", txHash.Hex())
+	timeoutSecs := inBlockTimeoutSecs
+	if untilFinalized {
+		timeoutSecs = finalizedTimeoutSecs
+	}
+	timeout := time.After(time.Duration(timeoutSecs) * time.Second)
 	for {
 		select {
 		case status := <-sub.Chan():
-			if status.IsInBlock {
-				blockHash := status.AsInBlock
-				logger = logger.WithValues("blockHash", blockHash)
-				if err != nil {
-					logger.Error(err, "SubmitAndWatchExtrinsic() error")
-					return &blockHash, txHash, err
-				}
-				evts, err := c.retriver.GetEvents(blockHash)
-				if err != nil {
-					return &blockHash, txHash, err
-				}
-				txIndex, err := c.retrieve_extrinsic_index_from_block(blockHash, *txHash)
-				if err != nil {
-					return &blockHash, txHash, err
-				}
-				for _, evt := range evts {
-					if evt.Phase.IsApplyExtrinsic && evt.Phase.AsApplyExtrinsic == uint32(txIndex) {
-						if evt.Name == "System.ExtrinsicFailed" {
-							for _, f := range evt.Fields {
-								if f.Name == "sp_runtime.DispatchError.dispatch_error" {
-									ss, err := json.Marshal(f.Value)
-									if err != nil {
-										logger.Error(err, "json marshal dispatch error value error")
-									}
-									err = errors.Errorf("extrinsic failed on chain: %s, blockHask: %s, txHash: %s", ss, blockHash.Hex(), txHash.Hex())
-									return &blockHash, txHash, err
-								}
-							}
-						}
-					}
-				}
-				return &blockHash, txHash, nil
+			var blockHash types.Hash
+			switch {
+			case status.IsInBlock && !untilFinalized:
+				blockHash = status.AsInBlock
+			case status.IsFinalized:
+				blockHash = status.AsFinalized
+			case status.IsDropped, status.IsInvalid, status.IsUsurped:
+				err := errors.Wrapf(ERR_TX_NOT_INCLUDED, "status: %s", extrinsicStatusName(status))
+				logger.Error(err, "")
+				return nil, err
+			default:
+				continue
+			}
+			logger = logger.WithValues("blockHash", blockHash.Hex())
+			if err := t.cc.checkExtrinsicDispatched(blockHash, txHash); err != nil {
+				logger.Error(err, "")
+				return &blockHash, err
+			}
+			return &blockHash, nil
+		case err := <-sub.Err():
+			if err == nil {
+				err = ERR_RPC_CONNECTION
 			}
+			logger.Error(err, "extrinsic status subscription error")
+			return nil, err
 		case <-timeout:
-			err = errors.Errorf("timeout of %d seconds reached without getting finalized status for extrinsic", timeoutSecs)
+			err := errors.Errorf("timeout of %d seconds reached without getting expected status for extrinsic", timeoutSecs)
 			logger.Error(err, "")
-			return nil, txHash, err
+			return nil, err
 		}
 	}
 }
 
+// checkExtrinsicDispatched looks up the events emitted by the extrinsic in the block,
+// it returns a DispatchError if a System.ExtrinsicFailed event is found.
+func (c *ChainClient) checkExtrinsicDispatched(blockHash, txHash types.Hash) error {
+	evts, err := c.retriver().GetEvents(blockHash)
+	if err != nil {
+		return err
+	}
+	txIndex, err := c.retrieve_extrinsic_index_from_block(blockHash, txHash)
+	if err != nil {
+		return err
+	}
+	return dispatchResult(evts, txIndex, blockHash, txHash)
+}
+
+func dispatchResult(evts []*parser.Event, txIndex uint32, blockHash, txHash types.Hash) error {
+	for _, evt := range evts {
+		if evt.Phase == nil || !evt.Phase.IsApplyExtrinsic || evt.Phase.AsApplyExtrinsic != txIndex {
+			continue
+		}
+		if evt.Name != "System.ExtrinsicFailed" {
+			continue
+		}
+		de := &DispatchError{BlockHash: blockHash, TxHash: txHash}
+		for _, f := range evt.Fields {
+			if f.Name == "sp_runtime.DispatchError.dispatch_error" {
+				ss, err := json.Marshal(f.Value)
+				if err != nil {
+					logger.Error(err, "json marshal dispatch error value error")
+				}
+				de.Detail = string(ss)
+			}
+		}
+		return de
+	}
+	return nil
+}
+
+func extrinsicStatusName(status types.ExtrinsicStatus) string {
+	switch {
+	case status.IsDropped:
+		return "dropped"
+	case status.IsInvalid:
+		return "invalid"
+	case status.IsUsurped:
+		return "usurped"
+	}
+	return "unknown"
+}
+
 func figureExtrinsicHash(ext *types.Extrinsic) (*types.Hash, error) {
 	b, err := codec.Encode(ext)
 	if err != nil {
@@ -296,10 +412,14 @@ func figureExtrinsicHash(ext *types.Extrinsic) (*types.Hash, error) {
 }
 
 func (t *ChainClient) retrieve_extrinsic_index_from_block(block_hash, tx_hash types.Hash) (uint32, error) {
-	block, err := t.api.RPC.Chain.GetBlock(block_hash)
+	block, err := t.api().RPC.Chain.GetBlock(block_hash)
 	if err != nil {
 		return 0, err
 	}
+	return extrinsicIndexInBlock(block, tx_hash)
+}
+
+func extrinsicIndexInBlock(block *types.SignedBlock, tx_hash types.Hash) (uint32, error) {
 	for i, ext := range block.Block.Extrinsics {
 		b, err := codec.Encode(ext)
 		if err != nil {
@@ -314,10 +434,6 @@ func (t *ChainClient) retrieve_extrinsic_index_from_block(block_hash, tx_hash ty
 	return 0, errors.New("ExtrinsicNotFound")
 }
 
-func reconnectChainClient(rpcAddr string) (*gsrpc.SubstrateAPI, error) {
-	return gsrpc.NewSubstrateAPI(rpcAddr)
-}
-
 func healthchek(a *gsrpc.SubstrateAPI) error {
 	defer func() {
 		recover()
@@ -1,3 +1,5 @@
+//go:build integration
+
 package chain
 
 import (
@@ -11,9 +13,10 @@ var (
 	phrase = "hire useless peanut engine amused fuel wet toddler list party salmon dream"
 )
 
+// TestTransfer needs a live node, run it with go test -tags integration
 func TestTransfer(t *testing.T) {
 	require := require.New(t)
-	c, err := NewChainClient("ws://221.122.79.5:9944/", phrase, 11330)
+	c, err := NewChainClient([]string{"ws://221.122.79.5:9944/"}, phrase, 11330)
 	require.NoError(err)
 	blockHash, txHash, err := c.TransferBySs58Address("cXie7akQvDPoWqwcaPUHx1GqugFHQKn3a9wPyRUYZQL4scmgU", big.NewInt(2))
 	require.NoError(err)
@@ -0,0 +1,125 @@
+package chain
+
+import (
+	"time"
+
+	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/retriever"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/state"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const (
+	healthCheckInterval  = 10 * time.Second
+	failoverRounds       = 5
+	failoverBackoff      = 1 * time.Second
+	failoverBackoffLimit = 30 * time.Second
+)
+
+// chainConn holds everything bound to the connection of one rpc endpoint,
+// it's replaced as a whole when the client reconnects.
+type chainConn struct {
+	endpoint       string
+	api            *gsrpc.SubstrateAPI
+	metadata       *types.Metadata
+	runtimeVersion *types.RuntimeVersion
+	retriver       retriever.EventRetriever
+}
+
+func dialChainConn(endpoint string) (*chainConn, error) {
+	api, err := gsrpc.NewSubstrateAPI(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := newChainConn(endpoint, api)
+	if err != nil {
+		api.Client.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func newChainConn(endpoint string, api *gsrpc.SubstrateAPI) (*chainConn, error) {
+	retv, err := retriever.NewDefaultEventRetriever(state.NewEventProvider(api.RPC.State), api.RPC.State)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, err
+	}
+	runtimeVersion, err := api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, err
+	}
+	return &chainConn{
+		endpoint:       endpoint,
+		api:            api,
+		metadata:       metadata,
+		runtimeVersion: runtimeVersion,
+		retriver:       retv,
+	}, nil
+}
+
+func (c *ChainClient) api() *gsrpc.SubstrateAPI {
+	return c.conn.Load().api
+}
+
+func (c *ChainClient) metadata() *types.Metadata {
+	return c.conn.Load().metadata
+}
+
+func (c *ChainClient) runtimeVersion() *types.RuntimeVersion {
+	return c.conn.Load().runtimeVersion
+}
+
+func (c *ChainClient) retriver() retriever.EventRetriever {
+	return c.conn.Load().retriver
+}
+
+// ActiveEndpoint returns the rpc endpoint in use
+func (c *ChainClient) ActiveEndpoint() string {
+	return c.conn.Load().endpoint
+}
+
+func (c *ChainClient) keepAlive() {
+	for {
+		time.Sleep(healthCheckInterval)
+		if !c.IsChainClientOk() {
+			logger.Error(ERR_RPC_CONNECTION, "all rpc endpoints are unavailable")
+		}
+	}
+}
+
+// failover connects to the endpoints in turn, starting from the one after the active endpoint,
+// with an exponential backoff between the rounds.
+func (c *ChainClient) failover() error {
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+	// another caller may have reconnected already
+	if healthchek(c.api()) == nil {
+		return nil
+	}
+	backoff := failoverBackoff
+	for round := 0; round < failoverRounds; round++ {
+		for i := 1; i <= len(c.rpcAddrs); i++ {
+			idx := (c.endpointIdx + i) % len(c.rpcAddrs)
+			conn, err := dialChainConn(c.rpcAddrs[idx])
+			if err != nil {
+				logger.Error(err, "connect rpc endpoint error", "endpoint", c.rpcAddrs[idx])
+				continue
+			}
+			old := c.conn.Swap(conn)
+			c.endpointIdx = idx
+			old.api.Client.Close()
+			logger.Info("switch rpc endpoint", "from", old.endpoint, "to", conn.endpoint)
+			return nil
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > failoverBackoffLimit {
+			backoff = failoverBackoffLimit
+		}
+	}
+	return ERR_RPC_CONNECTION
+}
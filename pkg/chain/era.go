@@ -0,0 +1,78 @@
+package chain
+
+import (
+	"math/bits"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const (
+	DEFAULT_ERA_PERIOD = 64
+	minEraPeriod       = 4
+	maxEraPeriod       = 1 << 16
+)
+
+// EraWindow is the range of blocks in which a mortal extrinsic is valid, death excluded
+type EraWindow struct {
+	Birth uint64 `json:"birth"`
+	Death uint64 `json:"death"`
+}
+
+// mortalEra follows the encoding of sp_runtime::generic::Era::mortal,
+// the period is rounded up to a power of two and clamped to [4, 65536].
+type mortalEra struct {
+	period uint64
+	phase  uint64
+}
+
+func newMortalEra(period, current uint64) mortalEra {
+	if period == 0 || period > maxEraPeriod {
+		period = maxEraPeriod
+	}
+	if period&(period-1) != 0 {
+		period = 1 << bits.Len64(period)
+	}
+	if period < minEraPeriod {
+		period = minEraPeriod
+	}
+	if period > maxEraPeriod {
+		period = maxEraPeriod
+	}
+	quantizeFactor := quantizeFactorOf(period)
+	phase := current % period / quantizeFactor * quantizeFactor
+	return mortalEra{period: period, phase: phase}
+}
+
+func quantizeFactorOf(period uint64) uint64 {
+	if f := period >> 12; f > 1 {
+		return f
+	}
+	return 1
+}
+
+func (e mortalEra) birth(current uint64) uint64 {
+	if current < e.phase {
+		current = e.phase
+	}
+	return (current-e.phase)/e.period*e.period + e.phase
+}
+
+func (e mortalEra) window(current uint64) EraWindow {
+	birth := e.birth(current)
+	return EraWindow{Birth: birth, Death: birth + e.period}
+}
+
+func (e mortalEra) encode() types.ExtrinsicEra {
+	low := uint64(bits.TrailingZeros64(e.period)) - 1
+	if low < 1 {
+		low = 1
+	}
+	if low > 15 {
+		low = 15
+	}
+	encoded := uint16(low) | uint16(e.phase/quantizeFactorOf(e.period))<<4
+	return types.ExtrinsicEra{
+		IsMortalEra: true,
+		AsMortalEra: types.MortalEra{First: byte(encoded), Second: byte(encoded >> 8)},
+	}
+}
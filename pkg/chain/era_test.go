@@ -0,0 +1,31 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the cases come from the tests of sp_runtime::generic::Era
+func TestMortalEraInitialization(t *testing.T) {
+	assert.Equal(t, mortalEra{period: 64, phase: 42}, newMortalEra(64, 42))
+	assert.Equal(t, mortalEra{period: 32768, phase: 20000}, newMortalEra(32768, 20000))
+	assert.Equal(t, mortalEra{period: 256, phase: 1}, newMortalEra(200, 513))
+	assert.Equal(t, mortalEra{period: 4, phase: 1}, newMortalEra(2, 1))
+	assert.Equal(t, mortalEra{period: 4, phase: 1}, newMortalEra(4, 5))
+	assert.Equal(t, mortalEra{period: 65536, phase: 1000001 % 65536 / 4 * 4}, newMortalEra(1000000, 1000001))
+}
+
+func TestMortalEraEncode(t *testing.T) {
+	era := newMortalEra(64, 42).encode()
+	assert.True(t, era.IsMortalEra)
+	assert.Equal(t, byte(5+42%16*16), era.AsMortalEra.First)
+	assert.Equal(t, byte(42/16), era.AsMortalEra.Second)
+}
+
+func TestMortalEraWindow(t *testing.T) {
+	era := newMortalEra(4, 6)
+	for i := uint64(6); i < 10; i++ {
+		assert.Equal(t, EraWindow{Birth: 6, Death: 10}, era.window(i))
+	}
+}
@@ -0,0 +1,159 @@
+package chain
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// EventField returns the value of the event field, the name is matched
+// with or without the type path prefix given by the event registry.
+func EventField(evt *parser.Event, name string) (any, bool) {
+	for _, f := range evt.Fields {
+		if f.Name == name || strings.HasSuffix(f.Name, "."+name) {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func EventAccountId(evt *parser.Event, name string) (*types.AccountID, error) {
+	b, err := EventBytes(evt, name)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewAccountID(b)
+}
+
+func EventBytes(evt *parser.Event, name string) ([]byte, error) {
+	v, ok := EventField(evt, name)
+	if !ok {
+		return nil, errors.Errorf("field %s not found in event %s", name, evt.Name)
+	}
+	return decodedBytes(v)
+}
+
+func EventBalance(evt *parser.Event, name string) (*big.Int, error) {
+	v, ok := EventField(evt, name)
+	if !ok {
+		return nil, errors.Errorf("field %s not found in event %s", name, evt.Name)
+	}
+	switch n := v.(type) {
+	case types.U128:
+		return new(big.Int).Set(n.Int), nil
+	case types.UCompact:
+		return new(big.Int).Set((*big.Int)(&n)), nil
+	case types.U64:
+		return new(big.Int).SetUint64(uint64(n)), nil
+	case types.U32:
+		return new(big.Int).SetUint64(uint64(n)), nil
+	}
+	return nil, errors.Errorf("unexpected balance type %T of field %s", v, name)
+}
+
+// EventOptionU32 returns the value of the Option<u32> field, nil for None
+func EventOptionU32(evt *parser.Event, name string) (*uint32, error) {
+	v, ok := EventField(evt, name)
+	if !ok {
+		return nil, errors.Errorf("field %s not found in event %s", name, evt.Name)
+	}
+	switch n := v.(type) {
+	case byte:
+		// the variant without value
+		if n == 0 {
+			return nil, nil
+		}
+	case types.U32:
+		u := uint32(n)
+		return &u, nil
+	case registry.DecodedFields:
+		if len(n) == 1 {
+			if u, ok := n[0].Value.(types.U32); ok {
+				return (*uint32)(&u), nil
+			}
+		}
+	}
+	return nil, errors.Errorf("unexpected option type %T of field %s", v, name)
+}
+
+// decodedBytes flattens the decoded value of byte arrays, byte vectors and
+// the single field composites wrapping them, such as AccountId32.
+func decodedBytes(v any) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case types.Bytes:
+		return b, nil
+	case registry.DecodedFields:
+		if len(b) != 1 {
+			return nil, errors.Errorf("unexpected composite with %d fields", len(b))
+		}
+		return decodedBytes(b[0].Value)
+	case []any:
+		res := make([]byte, 0, len(b))
+		for _, item := range b {
+			u, ok := item.(types.U8)
+			if !ok {
+				return nil, errors.Errorf("unexpected item type %T of bytes", item)
+			}
+			res = append(res, byte(u))
+		}
+		return res, nil
+	}
+	return nil, errors.Errorf("unexpected bytes type %T", v)
+}
+
+// TransferredAmount sums up the balance transfers between the accounts made by the extrinsic of the index
+func TransferredAmount(evts []*parser.Event, index uint32, from, to types.AccountID) (*big.Int, error) {
+	return transferredAmount(evts, EVT_BALANCES_TRANSFER, index, from, to, nil)
+}
+
+// TransferredAssetAmount sums up the transfers of the asset between the accounts made by the extrinsic of the index
+func TransferredAssetAmount(evts []*parser.Event, index uint32, assetId uint32, from, to types.AccountID) (*big.Int, error) {
+	return transferredAmount(evts, EVT_ASSETS_TRANSFERRED, index, from, to, func(evt *parser.Event) (bool, error) {
+		id, err := EventBalance(evt, "asset_id")
+		if err != nil {
+			return false, err
+		}
+		return id.IsUint64() && id.Uint64() == uint64(assetId), nil
+	})
+}
+
+func transferredAmount(evts []*parser.Event, name string, index uint32, from, to types.AccountID, match func(*parser.Event) (bool, error)) (*big.Int, error) {
+	sum := big.NewInt(0)
+	for _, evt := range evts {
+		if evt.Name != name || evt.Phase == nil || !evt.Phase.IsApplyExtrinsic || evt.Phase.AsApplyExtrinsic != index {
+			continue
+		}
+		if match != nil {
+			ok, err := match(evt)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		f, err := EventAccountId(evt, "from")
+		if err != nil {
+			return nil, err
+		}
+		t, err := EventAccountId(evt, "to")
+		if err != nil {
+			return nil, err
+		}
+		if *f != from || *t != to {
+			continue
+		}
+		amount, err := EventBalance(evt, "amount")
+		if err != nil {
+			return nil, err
+		}
+		sum.Add(sum, amount)
+	}
+	return sum, nil
+}
@@ -0,0 +1,508 @@
+package chain
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	fakeSpecVersion        = 1
+	fakeTransactionVersion = 1
+)
+
+// FakeChain is a deterministic chain kept in memory, every accepted extrinsic is sealed
+// into a block of its own at once. The platform account is signed with the given keyring.
+type FakeChain struct {
+	lock      sync.Mutex
+	sealed    *sync.Cond
+	keyring   signature.KeyringPair
+	networkId uint16
+	decimals  uint32
+	calls     map[string]types.CallIndex
+	blocks    []*fakeBlock
+	finalized uint64
+	nonces    map[types.AccountID]uint64
+	balances  map[types.AccountID]*big.Int
+	assets    map[uint32]map[types.AccountID]*big.Int
+	// the number of the forks that took over, the blocks of a fork differ from the ones they replace
+	forks uint32
+	// AutoFinalize finalizes every block once it's sealed, otherwise Finalize has to be called
+	AutoFinalize bool
+	// Dispatch gives the events emitted by the extrinsic, the returned error fails the extrinsic
+	Dispatch func(ext *types.Extrinsic) ([]*parser.Event, error)
+	// TxFee is the fee every extrinsic pays in the smallest unit, no fee event is emitted if it's nil
+	TxFee *big.Int
+}
+
+type fakeBlock struct {
+	hash   types.Hash
+	block  *types.SignedBlock
+	events []*parser.Event
+	failed map[types.Hash]error
+}
+
+func NewFakeChain(keyring signature.KeyringPair, networkId uint16, decimals uint32) *FakeChain {
+	t := &FakeChain{
+		keyring:      keyring,
+		networkId:    networkId,
+		decimals:     decimals,
+		calls:        make(map[string]types.CallIndex),
+		nonces:       make(map[types.AccountID]uint64),
+		balances:     make(map[types.AccountID]*big.Int),
+		assets:       make(map[uint32]map[types.AccountID]*big.Int),
+		AutoFinalize: true,
+	}
+	t.sealed = sync.NewCond(&t.lock)
+	t.seal(nil, nil, nil)
+	return t
+}
+
+// CallIndex returns the index of the call, the calls are indexed in the order of their first use
+func (t *FakeChain) CallIndex(callName string) types.CallIndex {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.callIndex(callName)
+}
+
+func (t *FakeChain) callIndex(callName string) types.CallIndex {
+	ci, ok := t.calls[callName]
+	if !ok {
+		n := len(t.calls)
+		ci = types.CallIndex{SectionIndex: uint8(n >> 8), MethodIndex: uint8(n)}
+		t.calls[callName] = ci
+	}
+	return ci
+}
+
+// NewCall is like types.NewCall with the call indexes of the fake chain
+func (t *FakeChain) NewCall(callName string, args ...any) (types.Call, error) {
+	var b []byte
+	for _, arg := range args {
+		e, err := codec.Encode(arg)
+		if err != nil {
+			return types.Call{}, err
+		}
+		b = append(b, e...)
+	}
+	return types.Call{CallIndex: t.CallIndex(callName), Args: b}, nil
+}
+
+// Finalize finalizes all the sealed blocks
+func (t *FakeChain) Finalize() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.finalized = uint64(len(t.blocks) - 1)
+	t.sealed.Broadcast()
+}
+
+// Reorg replaces the blocks above the number with as many empty blocks of a fork, the finalized
+// blocks can't be replaced. The nonces and the balances changed by the replaced blocks are kept.
+func (t *FakeChain) Reorg(number uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if number < t.finalized {
+		return errors.Errorf("the blocks up to %d are finalized", t.finalized)
+	}
+	replaced := len(t.blocks) - 1 - int(number)
+	t.blocks = t.blocks[:number+1]
+	t.forks++
+	for i := 0; i < replaced; i++ {
+		t.seal(nil, nil, nil)
+	}
+	return nil
+}
+
+// Balance returns the balance of the account in the smallest unit
+func (t *FakeChain) Balance(accountPubKey []byte) *big.Int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	id, err := types.NewAccountID(accountPubKey)
+	if err != nil || t.balances[*id] == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(t.balances[*id])
+}
+
+// MintAsset gives the account the amount of the asset in the smallest unit without a tx
+func (t *FakeChain) MintAsset(assetId uint32, accountPubKey []byte, amount *big.Int) error {
+	id, err := types.NewAccountID(accountPubKey)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.assets[assetId] == nil {
+		t.assets[assetId] = make(map[types.AccountID]*big.Int)
+	}
+	if t.assets[assetId][*id] == nil {
+		t.assets[assetId][*id] = big.NewInt(0)
+	}
+	t.assets[assetId][*id].Add(t.assets[assetId][*id], amount)
+	return nil
+}
+
+// EmitEvents seals a block with the events not emitted by any extrinsic
+func (t *FakeChain) EmitEvents(evts ...*parser.Event) types.Hash {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, evt := range evts {
+		if evt.Phase == nil {
+			evt.Phase = &types.Phase{IsFinalization: true}
+		}
+	}
+	return t.seal(nil, evts, nil).hash
+}
+
+// seal appends a block of the extrinsics, the lock must be held
+func (t *FakeChain) seal(exts []types.Extrinsic, evts []*parser.Event, failed map[types.Hash]error) *fakeBlock {
+	header := types.Header{Number: types.BlockNumber(len(t.blocks))}
+	if len(t.blocks) > 0 {
+		header.ParentHash = t.blocks[len(t.blocks)-1].hash
+	}
+	if t.forks > 0 {
+		fork, _ := codec.Encode(t.forks)
+		header.StateRoot = types.NewHash(blake2bSum(fork))
+	}
+	b, _ := codec.Encode(header)
+	if failed == nil {
+		failed = make(map[types.Hash]error)
+	}
+	fb := &fakeBlock{
+		hash:   types.NewHash(blake2bSum(b)),
+		block:  &types.SignedBlock{Block: types.Block{Header: header, Extrinsics: exts}},
+		events: evts,
+		failed: failed,
+	}
+	t.blocks = append(t.blocks, fb)
+	if t.AutoFinalize {
+		t.finalized = uint64(header.Number)
+	}
+	t.sealed.Broadcast()
+	return fb
+}
+
+func blake2bSum(b []byte) []byte {
+	a := blake2b.Sum256(b)
+	return a[:]
+}
+
+// include checks the nonce of the extrinsic and seals it, the lock must be held
+func (t *FakeChain) include(ext *types.Extrinsic, txHash types.Hash, evts []*parser.Event) (*fakeBlock, error) {
+	if !ext.IsSigned() || !ext.Signature.Signer.IsID {
+		return nil, errors.New("the extrinsic is not signed")
+	}
+	signer := ext.Signature.Signer.AsID
+	nonce := (*big.Int)(&ext.Signature.Nonce).Uint64()
+	switch next := t.nonces[signer]; {
+	case nonce < next:
+		return nil, errors.New("1010: Invalid Transaction: Transaction is outdated")
+	case nonce > next:
+		return nil, errors.New("1010: Invalid Transaction: Transaction will be valid in the future")
+	}
+	t.nonces[signer]++
+	var dispatchErr error
+	if t.Dispatch != nil {
+		var more []*parser.Event
+		more, dispatchErr = t.Dispatch(ext)
+		if dispatchErr == nil {
+			evts = append(evts, more...)
+		}
+	}
+	if t.TxFee != nil {
+		evts = append(evts, &parser.Event{
+			Name: EVT_TX_FEE_PAID,
+			Fields: registry.DecodedFields{
+				FakeAccountField("who", signer),
+				FakeBalanceField("actual_fee", t.TxFee),
+				FakeBalanceField("tip", big.NewInt(0)),
+			},
+		})
+	}
+	for _, evt := range evts {
+		evt.Phase = &types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 0}
+	}
+	block := t.seal([]types.Extrinsic{*ext}, evts, nil)
+	if dispatchErr != nil {
+		de := &DispatchError{BlockHash: block.hash, TxHash: txHash, Detail: dispatchErr.Error()}
+		block.failed[txHash] = de
+		return block, de
+	}
+	return block, nil
+}
+
+func (t *FakeChain) TransferBySs58Address(target string, amount *big.Int) (*types.Hash, *types.Hash, error) {
+	_, pubkey, err := subkey.SS58Decode(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := types.NewAccountID(pubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	from, err := types.NewAccountID(t.keyring.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.decimals)), nil)
+	amount = new(big.Int).Mul(amount, unit)
+	targetAddr, err := types.NewMultiAddressFromAccountID(pubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	call, err := t.NewCall(CALL_BALANCES_TRANSFER, targetAddr, types.NewUCompact(amount))
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := t.AccountNextIndex(t.keyring.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	options, _, err := t.MakeSignatureOptions(nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	ext := types.NewExtrinsic(call)
+	if err := ext.Sign(t.keyring, options); err != nil {
+		return nil, nil, errors.Wrap(err, "sign a call error")
+	}
+	txHash, err := figureExtrinsicHash(&ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	evt := &parser.Event{
+		Name: EVT_BALANCES_TRANSFER,
+		Fields: registry.DecodedFields{
+			FakeAccountField("from", *from),
+			FakeAccountField("to", *to),
+			FakeBalanceField("amount", amount),
+		},
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	block, err := t.include(&ext, *txHash, []*parser.Event{evt})
+	if block == nil {
+		return nil, txHash, err
+	}
+	if err == nil {
+		if t.balances[*to] == nil {
+			t.balances[*to] = big.NewInt(0)
+		}
+		t.balances[*to].Add(t.balances[*to], amount)
+	}
+	return &block.hash, txHash, err
+}
+
+func (t *FakeChain) AccountNextIndex(accountPubKey []byte) (uint64, error) {
+	id, err := types.NewAccountID(accountPubKey)
+	if err != nil {
+		return 0, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.nonces[*id], nil
+}
+
+func (t *FakeChain) GetAccountBalance(accountPubKey []byte) (*AccountBalance, error) {
+	id, err := types.NewAccountID(accountPubKey)
+	if err != nil {
+		return nil, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	free := big.NewInt(0)
+	if t.balances[*id] != nil {
+		free.Set(t.balances[*id])
+	}
+	return &AccountBalance{Free: free, Reserved: big.NewInt(0), Frozen: big.NewInt(0), Nonce: t.nonces[*id]}, nil
+}
+
+func (t *FakeChain) GetAssetBalance(assetId uint32, accountPubKey []byte) (*big.Int, error) {
+	id, err := types.NewAccountID(accountPubKey)
+	if err != nil {
+		return nil, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	balance := big.NewInt(0)
+	if t.assets[assetId][*id] != nil {
+		balance.Set(t.assets[assetId][*id])
+	}
+	return balance, nil
+}
+
+func (t *FakeChain) MakeSignatureOptions(nonce uint64) (types.SignatureOptions, *EraWindow, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	current := uint64(len(t.blocks) - 1)
+	era := newMortalEra(DEFAULT_ERA_PERIOD, current)
+	window := era.window(current)
+	return types.SignatureOptions{
+		BlockHash:          t.blocks[window.Birth].hash,
+		Era:                era.encode(),
+		GenesisHash:        t.blocks[0].hash,
+		Nonce:              types.NewUCompactFromUInt(nonce),
+		SpecVersion:        fakeSpecVersion,
+		Tip:                types.NewUCompactFromUInt(0),
+		TransactionVersion: fakeTransactionVersion,
+	}, &window, nil
+}
+
+func (t *FakeChain) SendTx1(signtx string) (string, error) {
+	ext, txHash, err := decodeSignedTx(signtx)
+	if err != nil {
+		return "", err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, err = t.include(ext, *txHash, nil)
+	return txHash.Hex(), err
+}
+
+func (t *FakeChain) QueryTx(txHash types.Hash, after uint64) (*TxResult, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	best := uint64(len(t.blocks) - 1)
+	// only the recent blocks are searched without a block to search from, as the client does
+	for number := best; number > after && (after > 0 || best-number < verifyLookbackBlocks); number-- {
+		block := t.blocks[number]
+		idx, err := extrinsicIndexInBlock(block.block, txHash)
+		if err != nil {
+			continue
+		}
+		res := &TxResult{
+			Status: TX_IN_BLOCK,
+			Included: &IncludedExtrinsic{
+				BlockHash:   block.hash,
+				BlockNumber: number,
+				TxHash:      txHash,
+				Index:       idx,
+				Extrinsic:   block.block.Block.Extrinsics[idx],
+			},
+			BestNumber: best,
+		}
+		res.Fee, _ = ExtrinsicFee(block.events, idx)
+		res.Finalized = number <= t.finalized
+		if err, ok := block.failed[txHash]; ok {
+			res.Status = TX_FAILED
+			res.Err = err
+		} else if res.Finalized {
+			res.Status = TX_FINALIZED
+		}
+		return res, nil
+	}
+	return &TxResult{Status: TX_PENDING, BestNumber: best}, nil
+}
+
+func (t *FakeChain) EstimateFee(extHex string) (*FeeInfo, error) {
+	var ext types.Extrinsic
+	if err := codec.DecodeFromHex(extHex, &ext); err != nil {
+		return nil, errors.Wrap(err, "decode extrinsic error")
+	}
+	fee := big.NewInt(0)
+	if t.TxFee != nil {
+		fee.Set(t.TxFee)
+	}
+	return &FeeInfo{Class: "normal", Fee: fee}, nil
+}
+
+func (t *FakeChain) MatchExpectedCall(ext *types.Extrinsic, expect ExpectedCall) error {
+	return matchExpectedCall(ext, expect, func(callName string) (types.CallIndex, error) {
+		return t.CallIndex(callName), nil
+	})
+}
+
+func (t *FakeChain) BlockEvents(blockHash types.Hash) ([]*parser.Event, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, block := range t.blocks {
+		if block.hash == blockHash {
+			return block.events, nil
+		}
+	}
+	return nil, errors.New("block not found")
+}
+
+func (t *FakeChain) NewBlockFollower(store HeightStore, handler BlockHandler) Follower {
+	return &fakeFollower{chain: t, store: store, handler: handler}
+}
+
+func (t *FakeChain) TokenDecimals() uint32 {
+	return t.decimals
+}
+
+type fakeFollower struct {
+	chain   *FakeChain
+	store   HeightStore
+	handler BlockHandler
+}
+
+// Run hands the finalized blocks over to the handler in order as BlockFollower does
+func (f *fakeFollower) Run() {
+	last, err := f.store.LoadHeight()
+	if err != nil {
+		logger.Error(err, "load the last processed height error")
+		return
+	}
+	t := f.chain
+	for {
+		t.lock.Lock()
+		if last == 0 && t.finalized > 0 {
+			last = t.finalized - 1
+		}
+		for last >= t.finalized {
+			t.sealed.Wait()
+		}
+		block := t.blocks[last+1]
+		t.lock.Unlock()
+		err := f.handler(&FollowedBlock{
+			Number: last + 1,
+			Hash:   block.hash,
+			Block:  block.block,
+			Events: block.events,
+		})
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		last++
+		if err := f.store.SaveHeight(last); err != nil {
+			logger.Error(err, "save the last processed height error", "number", last)
+		}
+	}
+}
+
+// FakeAccountField builds the event field of an AccountId32 in the form of the event registry
+func FakeAccountField(name string, id types.AccountID) *registry.DecodedField {
+	items := make([]any, len(id))
+	for i, b := range id {
+		items[i] = types.U8(b)
+	}
+	return &registry.DecodedField{
+		Name:  name,
+		Value: registry.DecodedFields{{Name: "[u8; 32]", Value: items}},
+	}
+}
+
+// FakeBalanceField builds the event field of an u128 balance in the form of the event registry
+func FakeBalanceField(name string, amount *big.Int) *registry.DecodedField {
+	return &registry.DecodedField{Name: name, Value: types.NewU128(*amount)}
+}
+
+// FakeBytesField builds the event field of a byte vector in the form of the event registry
+func FakeBytesField(name string, b []byte) *registry.DecodedField {
+	items := make([]any, len(b))
+	for i, v := range b {
+		items[i] = types.U8(v)
+	}
+	return &registry.DecodedField{Name: name, Value: items}
+}
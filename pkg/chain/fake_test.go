@@ -0,0 +1,202 @@
+package chain
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func signFakeTx(t *testing.T, fc *FakeChain, kr signature.KeyringPair, call types.Call) string {
+	nonce, err := fc.AccountNextIndex(kr.PublicKey)
+	require.NoError(t, err)
+	options, _, err := fc.MakeSignatureOptions(nonce)
+	require.NoError(t, err)
+	ext := types.NewExtrinsic(call)
+	require.NoError(t, ext.Sign(kr, options))
+	signtx, err := codec.EncodeToHex(ext)
+	require.NoError(t, err)
+	return signtx
+}
+
+func TestFakeChainTransfer(t *testing.T) {
+	require := require.New(t)
+	fc := NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+
+	blockHash, txHash, err := fc.TransferBySs58Address(bob.Address, big.NewInt(2))
+	require.NoError(err)
+	require.Equal("2000000000000", fc.Balance(bob.PublicKey).String())
+	nonce, err := fc.AccountNextIndex(signature.TestKeyringPairAlice.PublicKey)
+	require.NoError(err)
+	require.Equal(uint64(1), nonce)
+
+	res, err := fc.QueryTx(*txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_FINALIZED, res.Status)
+	require.Equal(*blockHash, res.Included.BlockHash)
+
+	evts, err := fc.BlockEvents(*blockHash)
+	require.NoError(err)
+	require.Len(evts, 1)
+	to, err := EventAccountId(evts[0], "to")
+	require.NoError(err)
+	require.Equal(bob.PublicKey, to[:])
+	amount, err := EventBalance(evts[0], "amount")
+	require.NoError(err)
+	require.Equal("2", FormatBalance(amount, fc.TokenDecimals()))
+}
+
+func TestFakeChainSendTx(t *testing.T) {
+	require := require.New(t)
+	fc := NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	fc.AutoFinalize = false
+	fc.TxFee = big.NewInt(1500)
+	bob, err := signature.KeyringPairFromSecret("//Bob", 42)
+	require.NoError(err)
+	token := types.NewBytes([]byte("file-hash"))
+	call, err := fc.NewCall(CALL_NFT_MINT, token)
+	require.NoError(err)
+	signtx := signFakeTx(t, fc, bob, call)
+	fc.EmitEvents()
+
+	txHashHex, err := fc.SendTx1(signtx)
+	require.NoError(err)
+	txHash, err := types.NewHashFromHexString(txHashHex)
+	require.NoError(err)
+	res, err := fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_IN_BLOCK, res.Status)
+	require.Equal(fc.TxFee, res.Fee)
+	fc.Finalize()
+	res, err = fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_FINALIZED, res.Status)
+
+	ext := &res.Included.Extrinsic
+	require.NoError(fc.MatchExpectedCall(ext, ExpectedCall{Signer: bob.PublicKey, CallName: CALL_NFT_MINT, Args: []any{token}}))
+	require.ErrorIs(fc.MatchExpectedCall(ext, ExpectedCall{Signer: signature.TestKeyringPairAlice.PublicKey}), ERR_TX_SIGNER_MISMATCH)
+	require.ErrorIs(fc.MatchExpectedCall(ext, ExpectedCall{CallName: CALL_NFT_BUY}), ERR_TX_CALL_MISMATCH)
+	require.ErrorIs(fc.MatchExpectedCall(ext, ExpectedCall{Args: []any{types.NewBytes([]byte("other"))}}), ERR_TX_CALL_MISMATCH)
+
+	// replayed with a used nonce
+	_, err = fc.SendTx1(signtx)
+	require.True(isStaleNonceError(err))
+
+	// an old tx is only found searching from a block before it, as the client does
+	number := res.Included.BlockNumber
+	for i := 0; i < verifyLookbackBlocks; i++ {
+		fc.EmitEvents()
+	}
+	res, err = fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_PENDING, res.Status)
+	res, err = fc.QueryTx(txHash, number-1)
+	require.NoError(err)
+	require.Equal(TX_FINALIZED, res.Status)
+}
+
+func TestFakeChainReorg(t *testing.T) {
+	require := require.New(t)
+	fc := NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	fc.AutoFinalize = false
+	call, err := fc.NewCall(CALL_NFT_MINT, types.NewBytes([]byte("file-hash")))
+	require.NoError(err)
+	txHashHex, err := fc.SendTx1(signFakeTx(t, fc, signature.TestKeyringPairAlice, call))
+	require.NoError(err)
+	txHash, err := types.NewHashFromHexString(txHashHex)
+	require.NoError(err)
+	res, err := fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_IN_BLOCK, res.Status)
+
+	// the including block is replaced by an empty block of the fork
+	require.NoError(fc.Reorg(res.Included.BlockNumber - 1))
+	reorged, err := fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_PENDING, reorged.Status)
+	require.Equal(res.Included.BlockNumber, reorged.BestNumber)
+	_, err = fc.BlockEvents(res.Included.BlockHash)
+	require.Error(err)
+
+	fc.Finalize()
+	require.Error(fc.Reorg(0))
+}
+
+func TestFakeChainDispatchError(t *testing.T) {
+	require := require.New(t)
+	fc := NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	fc.Dispatch = func(ext *types.Extrinsic) ([]*parser.Event, error) {
+		return nil, errors.New("Nft.NotOwner")
+	}
+	call, err := fc.NewCall(CALL_NFT_LIST, types.NewBytes([]byte("file-hash")))
+	require.NoError(err)
+	txHashHex, err := fc.SendTx1(signFakeTx(t, fc, signature.TestKeyringPairAlice, call))
+	var de *DispatchError
+	require.ErrorAs(err, &de)
+
+	txHash, err := types.NewHashFromHexString(txHashHex)
+	require.NoError(err)
+	res, err := fc.QueryTx(txHash, 0)
+	require.NoError(err)
+	require.Equal(TX_FAILED, res.Status)
+	require.ErrorAs(res.Err, &de)
+}
+
+type memHeightStore struct {
+	lock   sync.Mutex
+	height uint64
+}
+
+func (t *memHeightStore) LoadHeight() (uint64, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.height, nil
+}
+
+func (t *memHeightStore) SaveHeight(height uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.height = height
+	return nil
+}
+
+func TestFakeChainFollower(t *testing.T) {
+	require := require.New(t)
+	fc := NewFakeChain(signature.TestKeyringPairAlice, 42, 12)
+	fc.AutoFinalize = false
+	fc.EmitEvents(&parser.Event{Name: "Test.First"})
+	fc.EmitEvents(&parser.Event{Name: "Test.Second"})
+	fc.EmitEvents(&parser.Event{Name: "Test.Third"})
+
+	followed := make(chan string, 3)
+	store := &memHeightStore{height: 1}
+	go fc.NewBlockFollower(store, func(block *FollowedBlock) error {
+		for _, evt := range block.Events {
+			followed <- evt.Name
+		}
+		return nil
+	}).Run()
+
+	fc.Finalize()
+	for _, name := range []string{"Test.Second", "Test.Third"} {
+		select {
+		case got := <-followed:
+			require.Equal(name, got)
+		case <-time.After(time.Second):
+			t.Fatal("block not followed")
+		}
+	}
+	require.Eventually(func() bool {
+		h, _ := store.LoadHeight()
+		return h == 3
+	}, time.Second, 10*time.Millisecond)
+}
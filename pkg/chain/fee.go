@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+)
+
+// FeeInfo is the result of payment_queryInfo, the fee is in the smallest unit
+type FeeInfo struct {
+	Class  string
+	Weight json.RawMessage
+	Fee    *big.Int
+}
+
+type runtimeDispatchInfo struct {
+	Weight     json.RawMessage `json:"weight"`
+	Class      string          `json:"class"`
+	PartialFee json.RawMessage `json:"partialFee"`
+}
+
+// EstimateFee estimates the fee of the hex encoded extrinsic without the tip,
+// an unsigned extrinsic is signed by the platform account to get the length right.
+func (c *ChainClient) EstimateFee(extHex string) (*FeeInfo, error) {
+	var ext types.Extrinsic
+	if err := codec.DecodeFromHex(extHex, &ext); err != nil {
+		return nil, errors.Wrap(err, "decode extrinsic error")
+	}
+	if !ext.IsSigned() {
+		options, _, err := c.MakeSignatureOptions(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := ext.Sign(c.keyring, options); err != nil {
+			return nil, errors.Wrap(err, "sign the extrinsic for estimation error")
+		}
+	}
+	encoded, err := codec.EncodeToHex(ext)
+	if err != nil {
+		return nil, err
+	}
+	var info runtimeDispatchInfo
+	if err := c.api().Client.Call(&info, "payment_queryInfo", encoded); err != nil {
+		return nil, errors.Wrap(err, "[payment_queryInfo]")
+	}
+	fee, err := parseNumberOrHex(info.PartialFee)
+	if err != nil {
+		return nil, err
+	}
+	return &FeeInfo{Class: info.Class, Weight: info.Weight, Fee: fee}, nil
+}
+
+// parseNumberOrHex parses the u128 in rpc results, it may be a json number, a decimal string or a hex string
+func parseNumberOrHex(raw json.RawMessage) (*big.Int, error) {
+	s := strings.Trim(string(raw), `"`)
+	base := 10
+	if strings.HasPrefix(s, "0x") {
+		s, base = s[2:], 16
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, errors.Errorf("invalid number: %s", string(raw))
+	}
+	return n, nil
+}
+
+// ExtrinsicFee returns the fee paid by the extrinsic of the index from the events of its block
+func ExtrinsicFee(evts []*parser.Event, index uint32) (*big.Int, bool) {
+	for _, evt := range evts {
+		if evt.Name != EVT_TX_FEE_PAID || evt.Phase == nil || !evt.Phase.IsApplyExtrinsic || evt.Phase.AsApplyExtrinsic != index {
+			continue
+		}
+		fee, err := EventBalance(evt, "actual_fee")
+		if err != nil {
+			logger.Error(err, "decode fee paid event error")
+			return nil, false
+		}
+		return fee, true
+	}
+	return nil, false
+}
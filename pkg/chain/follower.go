@@ -0,0 +1,130 @@
+package chain
+
+import (
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/registry/parser"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+)
+
+const followerRetryInterval = 6 * time.Second
+
+// HeightStore persists the height of the last processed block
+type HeightStore interface {
+	LoadHeight() (uint64, error)
+	SaveHeight(height uint64) error
+}
+
+type FollowedBlock struct {
+	Number uint64
+	Hash   types.Hash
+	Block  *types.SignedBlock
+	Events []*parser.Event
+}
+
+// ExtrinsicHash returns the hash of the extrinsic that emitted the event,
+// nil is returned for the events not emitted by an extrinsic.
+func (b *FollowedBlock) ExtrinsicHash(evt *parser.Event) *types.Hash {
+	if evt.Phase == nil || !evt.Phase.IsApplyExtrinsic {
+		return nil
+	}
+	idx := int(evt.Phase.AsApplyExtrinsic)
+	if idx >= len(b.Block.Block.Extrinsics) {
+		return nil
+	}
+	h, err := figureExtrinsicHash(&b.Block.Block.Extrinsics[idx])
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+// BlockHandler handles a finalized block, the block is processed again if an error is returned
+type BlockHandler func(block *FollowedBlock) error
+
+// BlockFollower follows the finalized heads and hands every block over to the handler in order,
+// the blocks missed while the follower was down are backfilled from the persisted height.
+type BlockFollower struct {
+	cc      *ChainClient
+	store   HeightStore
+	handler BlockHandler
+	logger  logr.Logger
+}
+
+func (c *ChainClient) NewBlockFollower(store HeightStore, handler BlockHandler) Follower {
+	return &BlockFollower{
+		cc:      c,
+		store:   store,
+		handler: handler,
+		logger:  logger.WithName("blockFollower"),
+	}
+}
+
+// Run follows the chain until the process exits
+func (f *BlockFollower) Run() {
+	last, err := f.store.LoadHeight()
+	if err != nil {
+		f.logger.Error(err, "load the last processed height error")
+		return
+	}
+	for {
+		last = f.follow(last)
+		time.Sleep(followerRetryInterval)
+	}
+}
+
+func (f *BlockFollower) follow(last uint64) uint64 {
+	sub, err := f.cc.api().RPC.Chain.SubscribeFinalizedHeads()
+	if err != nil {
+		f.logger.Error(err, "subscribe finalized heads error")
+		return last
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case header := <-sub.Chan():
+			target := uint64(header.Number)
+			if last == 0 && target > 0 {
+				// nothing processed yet, start from the current finalized block
+				last = target - 1
+			}
+			for last < target {
+				if err := f.process(last + 1); err != nil {
+					f.logger.Error(err, "process block error", "number", last+1)
+					return last
+				}
+				last++
+				if err := f.store.SaveHeight(last); err != nil {
+					f.logger.Error(err, "save the last processed height error", "number", last)
+				}
+			}
+		case err := <-sub.Err():
+			f.logger.Error(err, "finalized heads subscription error")
+			return last
+		}
+	}
+}
+
+func (f *BlockFollower) process(number uint64) error {
+	api := f.cc.api()
+	blockHash, err := api.RPC.Chain.GetBlockHash(number)
+	if err != nil {
+		return errors.Wrap(err, "get block hash error")
+	}
+	block, err := api.RPC.Chain.GetBlock(blockHash)
+	if err != nil {
+		return errors.Wrap(err, "get block error")
+	}
+	evts, err := f.cc.retriver().GetEvents(blockHash)
+	if err != nil {
+		return errors.Wrap(err, "get events error")
+	}
+	return f.handler(&FollowedBlock{
+		Number: number,
+		Hash:   blockHash,
+		Block:  block,
+		Events: evts,
+	})
+}
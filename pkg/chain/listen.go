@@ -3,7 +3,10 @@ package chain
 import (
 	"vdo-platform/pkg/log"
 
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
 	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
 )
 
 const rpcConnNum = 16 * 1024
@@ -21,10 +24,58 @@ func InitRpcWorkPool() {
 	}
 }
 
+// SendTx1 submits a hex encoded signed extrinsic and waits until it is included in a block.
+// It returns the tx hash, and a *DispatchError if the extrinsic failed on chain.
 func (c *ChainClient) SendTx1(signtx string) (string, error) {
-	return "", nil
+	return c.sendSignedTx(signtx, false)
 }
 
+// SendTx2 is like SendTx1 but waits until the including block is finalized.
 func (c *ChainClient) SendTx2(signtx string) (string, error) {
-	panic("xxx")
+	return c.sendSignedTx(signtx, true)
+}
+
+// DecodeSignedTx decodes the hex encoded signed extrinsic along with its hash
+func DecodeSignedTx(signtx string) (*types.Extrinsic, *types.Hash, error) {
+	return decodeSignedTx(signtx)
+}
+
+// SignedTxHash returns the hash of a hex encoded signed extrinsic.
+func SignedTxHash(signtx string) (string, error) {
+	_, txHash, err := decodeSignedTx(signtx)
+	if err != nil {
+		return "", err
+	}
+	return txHash.Hex(), nil
+}
+
+func decodeSignedTx(signtx string) (*types.Extrinsic, *types.Hash, error) {
+	var ext types.Extrinsic
+	if err := codec.DecodeFromHex(signtx, &ext); err != nil {
+		return nil, nil, errors.Wrap(err, "decode signed extrinsic error")
+	}
+	if !ext.IsSigned() {
+		return nil, nil, errors.New("the extrinsic is not signed")
+	}
+	txHash, err := figureExtrinsicHash(&ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ext, txHash, nil
+}
+
+func (c *ChainClient) sendSignedTx(signtx string, untilFinalized bool) (string, error) {
+	ext, txHash, err := decodeSignedTx(signtx)
+	if err != nil {
+		return "", err
+	}
+	logger := logger.WithName("sendTx").WithValues("txHash", txHash.Hex())
+	sub, err := c.api().RPC.Author.SubmitAndWatchExtrinsic(*ext)
+	if err != nil {
+		logger.Error(err, "submit extrinsic error")
+		return txHash.Hex(), err
+	}
+	submiter := LoggerContexedExtrinsicSubmiter{logger, c}
+	_, err = submiter.watchExtrinsic(sub, *txHash, untilFinalized)
+	return txHash.Hex(), err
 }
@@ -0,0 +1,82 @@
+package chain
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vedhavyas/go-subkey/v2"
+)
+
+// signerNonce holds the next nonce of a signer, its lock serializes the submissions of the signer
+type signerNonce struct {
+	sync.Mutex
+	next   uint64
+	synced bool
+}
+
+// nonceManager keeps the nonces of the signers locally so that the concurrent submissions
+// don't have to wait for the previous ones to be included to get a right nonce.
+type nonceManager struct {
+	lock    sync.Mutex
+	signers map[string]*signerNonce
+}
+
+// acquire locks the nonce of the signer until it's released by the caller
+func (t *nonceManager) acquire(pubkey []byte) *signerNonce {
+	key := string(pubkey)
+	t.lock.Lock()
+	if t.signers == nil {
+		t.signers = make(map[string]*signerNonce)
+	}
+	sn, ok := t.signers[key]
+	if !ok {
+		sn = &signerNonce{}
+		t.signers[key] = sn
+	}
+	t.lock.Unlock()
+	sn.Lock()
+	return sn
+}
+
+// invalidate makes the next submission of the signer reconcile its nonce with the chain
+func (t *nonceManager) invalidate(pubkey []byte) {
+	sn := t.acquire(pubkey)
+	sn.synced = false
+	sn.Unlock()
+}
+
+// AccountNextIndex returns the next nonce of the account, the txs in the pool are counted
+func (c *ChainClient) AccountNextIndex(accountPubKey []byte) (uint64, error) {
+	var nonce uint64
+	address := subkey.SS58Encode(accountPubKey, c.networkId)
+	if err := c.api().Client.Call(&nonce, "system_accountNextIndex", address); err != nil {
+		return 0, errors.Wrap(err, "[system_accountNextIndex]")
+	}
+	return nonce, nil
+}
+
+// nextNonce returns the nonce to sign the next extrinsic of the signer with, the lock must be held
+func (c *ChainClient) nextNonce(sn *signerNonce, pubkey []byte) (uint64, error) {
+	if sn.synced {
+		return sn.next, nil
+	}
+	nonce, err := c.AccountNextIndex(pubkey)
+	if err != nil {
+		return 0, err
+	}
+	sn.next, sn.synced = nonce, true
+	return nonce, nil
+}
+
+// isStaleNonceError tells whether the extrinsic is rejected by the pool because of its nonce,
+// it's either used by an included one or by a pending one with the same or higher priority.
+func isStaleNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "priority is too low") ||
+		strings.Contains(msg, "transaction is outdated") ||
+		strings.Contains(msg, "stale")
+}
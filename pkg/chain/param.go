@@ -17,6 +17,8 @@
 package chain
 
 import (
+	"fmt"
+
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/pkg/errors"
 )
@@ -28,18 +30,47 @@ const (
 	pallet_Sminer      = "Sminer"
 	pallet_SegmentBook = "SegmentBook"
 	pallet_System      = "System"
+	pallet_Assets      = "Assets"
 	pallet_Oss         = "Oss"
 )
 
 // Pallet's method
 const (
-	// System
+	// System, Assets
 	account = "Account"
 	events  = "Events"
 )
 
 // Extrinsics
-const ()
+const (
+	CALL_BALANCES_TRANSFER = "Balances.transfer"
+	CALL_ASSETS_TRANSFER   = "Assets.transfer"
+	CALL_NFT_MINT          = "Nft.mint"
+	CALL_NFT_TRANSFER      = "Nft.transfer"
+	CALL_NFT_LIST          = "Nft.list"
+	CALL_NFT_UNLIST        = "Nft.unlist"
+	CALL_NFT_UPDATE_PRICE  = "Nft.update_price"
+	CALL_NFT_BUY           = "Nft.buy"
+	CALL_NFT_BURN          = "Nft.burn"
+	CALL_UTILITY_BATCH_ALL = "Utility.batch_all"
+)
+
+// Events
+const (
+	EVT_BALANCES_TRANSFER     = "Balances.Transfer"
+	EVT_ASSETS_TRANSFERRED    = "Assets.Transferred"
+	EVT_TX_FEE_PAID           = "TransactionPayment.TransactionFeePaid"
+	EVT_FILEBANK_UPLOAD       = "FileBank.UploadDeclaration"
+	EVT_FILEBANK_STORAGE_DONE = "FileBank.StorageCompleted"
+	EVT_FILEBANK_DELETE       = "FileBank.DeleteFile"
+	EVT_NFT_MINTED            = "Nft.Minted"
+	EVT_NFT_TRANSFERRED       = "Nft.Transferred"
+	EVT_NFT_LISTED            = "Nft.Listed"
+	EVT_NFT_UNLISTED          = "Nft.Unlisted"
+	EVT_NFT_PRICE_UPDATED     = "Nft.PriceUpdated"
+	EVT_NFT_SOLD              = "Nft.Sold"
+	EVT_NFT_BURNED            = "Nft.Burned"
+)
 
 const (
 	FILE_STATE_ACTIVE  = "active"
@@ -58,6 +89,12 @@ var (
 	ERR_RPC_IP_FORMAT   = errors.New("unsupported ip format")
 	ERR_RPC_TIMEOUT     = errors.New("timeout")
 	ERR_RPC_EMPTY_VALUE = errors.New("empty")
+
+	ERR_TX_NOT_FOUND       = errors.New("extrinsic not found in recent blocks")
+	ERR_TX_SIGNER_MISMATCH = errors.New("extrinsic signer mismatch")
+	ERR_TX_CALL_MISMATCH   = errors.New("extrinsic call mismatch")
+	// the extrinsic is out of the pool, dropped, invalid or usurped, so it's safe to submit the call again
+	ERR_TX_NOT_INCLUDED = errors.New("extrinsic was not included in any block")
 )
 
 type EventEmitted struct {
@@ -65,3 +102,15 @@ type EventEmitted struct {
 	To   types.AccountID
 	Id   types.U8
 }
+
+// DispatchError means the extrinsic was included in a block but its dispatch failed,
+// Detail holds the json of the dispatch error reported by the System.ExtrinsicFailed event.
+type DispatchError struct {
+	BlockHash types.Hash
+	TxHash    types.Hash
+	Detail    string
+}
+
+func (e *DispatchError) Error() string {
+	return fmt.Sprintf("extrinsic failed on chain: %s, blockHash: %s, txHash: %s", e.Detail, e.BlockHash.Hex(), e.TxHash.Hex())
+}
@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/rpc/state"
+)
+
+const runtimeWatchRetryInterval = 6 * time.Second
+
+// watchRuntimeUpgrade keeps the metadata and runtime version up to date across runtime upgrades
+func (c *ChainClient) watchRuntimeUpgrade() {
+	for {
+		sub, err := c.api().RPC.State.SubscribeRuntimeVersion()
+		if err != nil {
+			logger.Error(err, "subscribe runtime version error")
+		} else {
+			c.followRuntimeVersion(sub)
+		}
+		time.Sleep(runtimeWatchRetryInterval)
+	}
+}
+
+func (c *ChainClient) followRuntimeVersion(sub *state.RuntimeVersionSubscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case rv := <-sub.Chan():
+			cur := c.runtimeVersion()
+			if rv.SpecVersion == cur.SpecVersion && rv.TransactionVersion == cur.TransactionVersion {
+				continue
+			}
+			logger.Info("runtime upgraded", "specVersion", rv.SpecVersion, "transactionVersion", rv.TransactionVersion)
+			if _, err := c.refreshRuntime(); err != nil {
+				logger.Error(err, "refresh runtime error")
+			}
+		case err := <-sub.Err():
+			// the connection may have been replaced by a failover
+			logger.Error(err, "runtime version subscription error")
+			return
+		}
+	}
+}
+
+// refreshRuntime reloads the metadata and runtime version of the active connection
+// and swaps them in at once, it reports whether the runtime version has changed.
+func (c *ChainClient) refreshRuntime() (bool, error) {
+	old := c.conn.Load()
+	conn, err := newChainConn(old.endpoint, old.api)
+	if err != nil {
+		return false, err
+	}
+	changed := conn.runtimeVersion.SpecVersion != old.runtimeVersion.SpecVersion ||
+		conn.runtimeVersion.TransactionVersion != old.runtimeVersion.TransactionVersion
+	if !c.conn.CompareAndSwap(old, conn) {
+		// replaced by a failover meanwhile, which has loaded the latest runtime as well
+		return true, nil
+	}
+	return changed, nil
+}
+
+// an extrinsic signed with an outdated spec version or transaction version has a bad signature
+func isOutdatedRuntimeError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "bad signature")
+}
@@ -0,0 +1,244 @@
+package chain
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/pkg/errors"
+)
+
+// how many blocks back from the best block are searched for a reported extrinsic
+const verifyLookbackBlocks = 100
+
+// ExpectedCall describes what a client reported extrinsic is supposed to be.
+type ExpectedCall struct {
+	// public key of the account that must have signed the extrinsic
+	Signer []byte
+	// call name in the form of "Pallet.call"
+	CallName string
+	// scale encodable args, their encoding must be a prefix of the call args
+	Args []any
+	// the leading calls batched by a Utility call, the args of the batched calls must be complete
+	Calls []ExpectedCall
+}
+
+type IncludedExtrinsic struct {
+	BlockHash   types.Hash
+	BlockNumber uint64
+	TxHash      types.Hash
+	Index       uint32
+	Extrinsic   types.Extrinsic
+}
+
+// VerifyExtrinsic looks up the extrinsic by hash in the recent blocks and checks that
+// it has been dispatched successfully and matches the expected signer, call and args.
+func (c *ChainClient) VerifyExtrinsic(txHashHex string, expect ExpectedCall) (*IncludedExtrinsic, error) {
+	txHash, err := types.NewHashFromHexString(txHashHex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tx hash")
+	}
+	ie, err := c.FindExtrinsic(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkExtrinsicDispatched(ie.BlockHash, txHash); err != nil {
+		return ie, err
+	}
+	if err := c.MatchExpectedCall(&ie.Extrinsic, expect); err != nil {
+		return ie, err
+	}
+	return ie, nil
+}
+
+// FindExtrinsic walks back from the best block to find the extrinsic in the recent blocks.
+func (c *ChainClient) FindExtrinsic(txHash types.Hash) (*IncludedExtrinsic, error) {
+	ie, _, err := c.FindExtrinsicAfter(txHash, 0)
+	return ie, err
+}
+
+// FindExtrinsicAfter is like FindExtrinsic but stops at the given block number instead of
+// the recent blocks unless it's 0, it also returns the number of the best block that the search started from.
+func (c *ChainClient) FindExtrinsicAfter(txHash types.Hash, after uint64) (*IncludedExtrinsic, uint64, error) {
+	blockHash, err := c.api().RPC.Chain.GetBlockHashLatest()
+	if err != nil {
+		return nil, 0, err
+	}
+	var best uint64
+	for i := 0; after > 0 || i < verifyLookbackBlocks; i++ {
+		block, err := c.api().RPC.Chain.GetBlock(blockHash)
+		if err != nil {
+			return nil, best, err
+		}
+		number := uint64(block.Block.Header.Number)
+		if i == 0 {
+			best = number
+		}
+		if number <= after {
+			break
+		}
+		idx, err := extrinsicIndexInBlock(block, txHash)
+		if err == nil {
+			return &IncludedExtrinsic{
+				BlockHash:   blockHash,
+				BlockNumber: number,
+				TxHash:      txHash,
+				Index:       idx,
+				Extrinsic:   block.Block.Extrinsics[idx],
+			}, best, nil
+		}
+		if number == 0 {
+			break
+		}
+		blockHash = block.Block.Header.ParentHash
+	}
+	return nil, best, ERR_TX_NOT_FOUND
+}
+
+type TxStatus int32
+
+const (
+	TX_PENDING TxStatus = iota
+	TX_IN_BLOCK
+	TX_FINALIZED
+	TX_FAILED
+)
+
+type TxResult struct {
+	Status   TxStatus
+	Included *IncludedExtrinsic
+	// number of the best block when queried
+	BestNumber uint64
+	// the dispatch error of a failed tx
+	Err error
+	// the including block is finalized, a failed tx is only final then
+	Finalized bool
+	// the fee paid by the tx in the smallest unit, nil if unknown
+	Fee *big.Int
+}
+
+// QueryTx reports the status of the extrinsic, only the blocks after the given block number are searched.
+func (c *ChainClient) QueryTx(txHash types.Hash, after uint64) (*TxResult, error) {
+	ie, best, err := c.FindExtrinsicAfter(txHash, after)
+	if err == ERR_TX_NOT_FOUND {
+		return &TxResult{Status: TX_PENDING, BestNumber: best}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &TxResult{Status: TX_IN_BLOCK, Included: ie, BestNumber: best}
+	evts, err := c.retriver().GetEvents(ie.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	res.Fee, _ = ExtrinsicFee(evts, ie.Index)
+	if res.Finalized, err = c.isBlockFinalized(ie.BlockNumber, ie.BlockHash); err != nil {
+		return nil, err
+	}
+	if err := dispatchResult(evts, ie.Index, ie.BlockHash, txHash); err != nil {
+		var de *DispatchError
+		if !errors.As(err, &de) {
+			return nil, err
+		}
+		res.Status = TX_FAILED
+		res.Err = err
+		return res, nil
+	}
+	if res.Finalized {
+		res.Status = TX_FINALIZED
+	}
+	return res, nil
+}
+
+func (c *ChainClient) isBlockFinalized(number uint64, blockHash types.Hash) (bool, error) {
+	finalizedHash, err := c.api().RPC.Chain.GetFinalizedHead()
+	if err != nil {
+		return false, err
+	}
+	header, err := c.api().RPC.Chain.GetHeader(finalizedHash)
+	if err != nil {
+		return false, err
+	}
+	if uint64(header.Number) < number {
+		return false, nil
+	}
+	canonicalHash, err := c.api().RPC.Chain.GetBlockHash(number)
+	if err != nil {
+		return false, err
+	}
+	return canonicalHash == blockHash, nil
+}
+
+// MatchExpectedCall checks the extrinsic against the expected signer, call and args.
+func (c *ChainClient) MatchExpectedCall(ext *types.Extrinsic, expect ExpectedCall) error {
+	return matchExpectedCall(ext, expect, c.metadata().FindCallIndex)
+}
+
+func matchExpectedCall(ext *types.Extrinsic, expect ExpectedCall, findCallIndex func(string) (types.CallIndex, error)) error {
+	if len(expect.Signer) > 0 {
+		signer := ext.Signature.Signer
+		if !ext.IsSigned() || !signer.IsID || !bytes.Equal(signer.AsID[:], expect.Signer) {
+			return ERR_TX_SIGNER_MISMATCH
+		}
+	}
+	if expect.CallName != "" {
+		callIndex, err := findCallIndex(expect.CallName)
+		if err != nil {
+			return err
+		}
+		if ext.Method.CallIndex != callIndex {
+			return errors.Wrap(ERR_TX_CALL_MISMATCH, expect.CallName)
+		}
+	}
+	var expectArgs []byte
+	for _, arg := range expect.Args {
+		b, err := codec.Encode(arg)
+		if err != nil {
+			return errors.Wrap(err, "scale encode expected arg error")
+		}
+		expectArgs = append(expectArgs, b...)
+	}
+	if !bytes.HasPrefix(ext.Method.Args, expectArgs) {
+		return errors.Wrap(ERR_TX_CALL_MISMATCH, "call args")
+	}
+	if len(expect.Calls) > 0 {
+		return matchBatchedCalls(ext.Method.Args, expect.Calls, findCallIndex)
+	}
+	return nil
+}
+
+func matchBatchedCalls(args []byte, calls []ExpectedCall, findCallIndex func(string) (types.CallIndex, error)) error {
+	r := bytes.NewReader(args)
+	var n types.UCompact
+	if err := scale.NewDecoder(r).Decode(&n); err != nil {
+		return errors.Wrap(ERR_TX_CALL_MISMATCH, "batched calls")
+	}
+	if (*big.Int)(&n).Cmp(big.NewInt(int64(len(calls)))) < 0 {
+		return errors.Wrap(ERR_TX_CALL_MISMATCH, "number of batched calls")
+	}
+	rest := args[len(args)-r.Len():]
+	for _, call := range calls {
+		callIndex, err := findCallIndex(call.CallName)
+		if err != nil {
+			return err
+		}
+		expectCall, err := codec.Encode(callIndex)
+		if err != nil {
+			return err
+		}
+		for _, arg := range call.Args {
+			b, err := codec.Encode(arg)
+			if err != nil {
+				return errors.Wrap(err, "scale encode expected arg error")
+			}
+			expectCall = append(expectCall, b...)
+		}
+		if !bytes.HasPrefix(rest, expectCall) {
+			return errors.Wrap(ERR_TX_CALL_MISMATCH, "batched call "+call.CallName)
+		}
+		rest = rest[len(expectCall):]
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package oracle
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+var ERR_NO_RATE = errors.New("no exchange rate of the currency")
+
+// PriceOracle gives the exchange rates of the currencies to a single fiat currency,
+// the fiat values shown to the buyers are only approximate.
+type PriceOracle interface {
+	// Fiat is the fiat currency the rates are quoted in, such as USD
+	Fiat() string
+	// Rate is the fiat value of one unit of the currency, ERR_NO_RATE if it's unknown
+	Rate(symbol string) (*big.Rat, error)
+}
+
+// FiatValue is the fiat value of the amount given in the smallest unit of a currency with the decimals
+func FiatValue(o PriceOracle, symbol string, amount *big.Int, decimals uint32) (*big.Rat, error) {
+	rate, err := o.Rate(symbol)
+	if err != nil {
+		return nil, err
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	v := new(big.Rat).SetFrac(amount, unit)
+	return v.Mul(v, rate), nil
+}
@@ -0,0 +1,64 @@
+package oracle
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StaticOracle keeps the exchange rates read from a file, for the offline deployments
+// and the tests. The file is like:
+//
+//	{"fiat": "USD", "rates": {"CESS": "0.012", "USDT": "1"}}
+type StaticOracle struct {
+	fiat  string
+	rates map[string]*big.Rat
+}
+
+func NewStaticOracle(file string) (*StaticOracle, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read the rates file error")
+	}
+	return ParseStaticRates(b)
+}
+
+// ParseStaticRates parses the rates in the form of the rates file
+func ParseStaticRates(b []byte) (*StaticOracle, error) {
+	var f struct {
+		Fiat  string            `json:"fiat"`
+		Rates map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, errors.Wrap(err, "parse the rates error")
+	}
+	if f.Fiat == "" {
+		return nil, errors.New("the fiat currency of the rates is missing")
+	}
+	t := &StaticOracle{fiat: strings.ToUpper(f.Fiat), rates: make(map[string]*big.Rat, len(f.Rates))}
+	for symbol, s := range f.Rates {
+		rate, ok := new(big.Rat).SetString(s)
+		if !ok || rate.Sign() < 0 {
+			return nil, errors.Errorf("invalid rate %q of %s", s, symbol)
+		}
+		t.rates[strings.ToUpper(symbol)] = rate
+	}
+	return t, nil
+}
+
+func (t *StaticOracle) Fiat() string {
+	return t.fiat
+}
+
+func (t *StaticOracle) Rate(symbol string) (*big.Rat, error) {
+	rate, ok := t.rates[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, errors.Wrap(ERR_NO_RATE, symbol)
+	}
+	return new(big.Rat).Set(rate), nil
+}
+
+var _ PriceOracle = (*StaticOracle)(nil)
@@ -0,0 +1,40 @@
+package oracle
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticOracle(t *testing.T) {
+	require := require.New(t)
+	file := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(os.WriteFile(file, []byte(`{"fiat": "usd", "rates": {"CESS": "0.012", "usdt": "1"}}`), 0644))
+	o, err := NewStaticOracle(file)
+	require.NoError(err)
+	require.Equal("USD", o.Fiat())
+
+	rate, err := o.Rate("cess")
+	require.NoError(err)
+	require.Equal("0.012", rate.FloatString(3))
+	_, err = o.Rate("DOT")
+	require.ErrorIs(err, ERR_NO_RATE)
+
+	// 1.5 CESS in planck with 12 decimals
+	v, err := FiatValue(o, "CESS", big.NewInt(1500000000000), 12)
+	require.NoError(err)
+	require.Equal("0.018", v.FloatString(3))
+	v, err = FiatValue(o, "USDT", big.NewInt(2500000), 6)
+	require.NoError(err)
+	require.Equal("2.50", v.FloatString(2))
+
+	_, err = ParseStaticRates([]byte(`{"rates": {"CESS": "1"}}`))
+	require.Error(err)
+	_, err = ParseStaticRates([]byte(`{"fiat": "USD", "rates": {"CESS": "-1"}}`))
+	require.Error(err)
+	_, err = NewStaticOracle(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(err)
+}
@@ -25,3 +25,17 @@ func QueryByPaging(pr Paging, listResult any, listQ *gorm.DB, cntQ *gorm.DB) (Pa
 	}
 	return NewPagingResult(pr, uint(totalRecords), totalPages, listResult), err
 }
+
+// MapList pages the rows like QueryByPaging, then maps every row to the item of the list
+func MapList[R, T any](pr Paging, listQ *gorm.DB, cntQ *gorm.DB, mapRow func(R) T) (PagingResulter, error) {
+	var rows []R
+	res, err := QueryByPaging(pr, &rows, listQ, cntQ)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, mapRow(row))
+	}
+	return NewPagingResult(pr, res.TotalRecords(), res.TotalPages(), list), nil
+}
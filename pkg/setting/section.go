@@ -27,6 +27,39 @@ type Web3SettingS struct {
 	RpcEndpoints  []string
 	Mnemonic      string
 	SupperAddress string
+	// percentage of every sale paid to the SupperAddress as the platform fee
+	PlatformFeeRate uint32
+	// the number of blocks a signed extrinsic is valid for
+	EraPeriod uint64
+	// symbol of the chain token, the prices are in the chain token unless another currency is given
+	TokenSymbol string
+	// assets of the asset pallet the nfts can be priced in, such as a stablecoin
+	Assets []AssetSettingS
+}
+
+type AssetSettingS struct {
+	Symbol   string
+	AssetId  uint32
+	Decimals uint32
+}
+
+type PriceOracleSettingS struct {
+	// the source of the exchange rates, "static" reads them from the File, empty for no fiat values
+	Source string
+	File   string
+}
+
+type FaucetSettingS struct {
+	Enabled bool
+	// tokens granted to every new account
+	Amount int64
+	// grants per account, per client ip in a day and in total in a day, 0 means no limit
+	PerAccountLimit int64
+	PerIpDailyLimit int64
+	DailyLimit      int64
+	// tokens the faucet can give out in total, the faucet is switched off when it runs out, 0 means no limit
+	Budget     int64
+	MaxRetries int
 }
 
 type SmtpSettingS struct {
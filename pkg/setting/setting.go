@@ -13,13 +13,15 @@ import (
 )
 
 type Settings struct {
-	ServerSetting   *ServerSettingS
-	AppSetting      *AppSettingS
-	Web3Setting     *Web3SettingS
-	DatabaseSetting *DatabaseSettingS
-	RedisSetting    *RedisSettingS
-	SmtpSetting     *SmtpSettingS
-	vp              *viper.Viper
+	ServerSetting      *ServerSettingS
+	AppSetting         *AppSettingS
+	Web3Setting        *Web3SettingS
+	DatabaseSetting    *DatabaseSettingS
+	RedisSetting       *RedisSettingS
+	SmtpSetting        *SmtpSettingS
+	FaucetSetting      *FaucetSettingS
+	PriceOracleSetting *PriceOracleSettingS
+	vp                 *viper.Viper
 }
 
 func (t *Settings) ChangePassword(newPassword, oldPassword string) error {
@@ -121,6 +123,12 @@ func NewSettingsWithDirectory(configDir string) (*Settings, error) {
 	if err := vp.UnmarshalKey("Smtp", &pSettings.SmtpSetting); err != nil {
 		return nil, err
 	}
+	if err := vp.UnmarshalKey("Faucet", &pSettings.FaucetSetting); err != nil {
+		return nil, err
+	}
+	if err := vp.UnmarshalKey("PriceOracle", &pSettings.PriceOracleSetting); err != nil {
+		return nil, err
+	}
 
 	return pSettings, nil
 }